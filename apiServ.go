@@ -1,13 +1,36 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sync"
 
+	"github.com/TheHackerDev/race-the-web/race"
+	"github.com/TheHackerDev/race-the-web/report"
 	"github.com/gin-gonic/gin"
 )
 
+// configuration is the configuration set through the API, used by the next race started. It is only read and
+// written through savedConfig and saveConfig, as requests are handled concurrently.
+var configuration race.Configuration
+var configurationMutex sync.RWMutex
+
+// savedConfig returns a copy of the configuration set through the API, which shares nothing with it
+func savedConfig() race.Configuration {
+	configurationMutex.RLock()
+	defer configurationMutex.RUnlock()
+	return configuration.Copy()
+}
+
+// saveConfig replaces the configuration set through the API
+func saveConfig(config race.Configuration) {
+	configurationMutex.Lock()
+	defer configurationMutex.Unlock()
+	configuration = config
+}
+
 // StartAPI starts the API server.
 func StartAPI() {
 	// Set Gin configuration mode
@@ -15,17 +38,27 @@ func StartAPI() {
 
 	// Configure & Start the HTTP API server
 	router := gin.Default()
+	addRoutes(router)
+
+	router.Run("127.0.0.1:8000")
+}
+
+// addRoutes registers the API endpoints with the router
+func addRoutes(router *gin.Engine) {
 	router.GET("/get/config", GetConfig)
 	router.POST("/set/config", SetConfig)
 	router.POST("/start", APIStart)
-
-	router.Run("127.0.0.1:8000")
+	router.GET("/jobs", ListJobs)
+	router.POST("/jobs", StartJob)
+	router.GET("/jobs/:id", GetJob)
+	router.DELETE("/jobs/:id", DeleteJob)
+	router.GET("/jobs/:id/events", StreamJobEvents)
 }
 
 // API endpoint to set the configuration options
 func SetConfig(ctx *gin.Context) {
 	// Validate input
-	var config Configuration // temporary variable required for proper validation
+	var config race.Configuration // temporary variable required for proper validation
 	if ctx.BindJSON(&config) != nil {
 		// Invalid JSON sent
 		ctx.JSON(http.StatusBadRequest, gin.H{
@@ -34,11 +67,19 @@ func SetConfig(ctx *gin.Context) {
 		return
 	}
 
+	// Files are not read on behalf of API clients
+	if err := checkAPIConfig(config); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
 	// Set defaults
-	SetDefaults(&config)
+	race.SetDefaults(&config)
 
 	// Assign to global configuration object
-	configuration = config
+	saveConfig(config)
 
 	// Send response
 	ctx.JSON(http.StatusOK, gin.H{
@@ -48,8 +89,10 @@ func SetConfig(ctx *gin.Context) {
 
 // API endpoint to retrieve the high-level configuration
 func GetConfig(ctx *gin.Context) {
+	config := savedConfig()
+
 	// Check if the configuration exists
-	if len(configuration.Requests) == 0 {
+	if len(config.Requests) == 0 {
 		// No configuration currently exists
 		ctx.JSON(http.StatusBadRequest, gin.H{
 			"message": "no configuration set",
@@ -58,25 +101,93 @@ func GetConfig(ctx *gin.Context) {
 	}
 
 	// Send response
-	ctx.JSON(http.StatusOK, configuration)
+	ctx.JSON(http.StatusOK, config)
 }
 
 // API endpoint to begin the race test using the configuration file already provided.
+// The results are sent as a report instead if a format is given, such as ?format=junit.
 func APIStart(ctx *gin.Context) {
+	// Check the report format before starting
+	format := ctx.Query("format")
+	if format == "json" {
+		format = ""
+	}
+	if format != "" && !validReportFormat(format) {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"message": fmt.Sprintf("unknown report format %q", format),
+		})
+		return
+	}
+
 	// Run race test, returning any initial errors
-	err, responses := StartRace()
+	// The race is stopped if the client disconnects
+	config := savedConfig()
+	runner := race.NewRunner(config)
+	runner.Record = report.Recorded(format)
+	result, err := runner.Run(ctx.Request.Context())
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"message": fmt.Sprintf("error: %s", err.Error()),
 		})
+		return
+	}
+	outputErrors(result.Errors)
+
+	if format != "" {
+		sendReport(ctx, format, config, result)
+		return
+	}
+	writeJSON(ctx, http.StatusOK, result.Responses)
+}
+
+// sendReport sends a report of the result in the given format as the response body
+func sendReport(ctx *gin.Context, format string, config race.Configuration, result *race.Result) {
+	if !validReportFormat(format) {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"message": fmt.Sprintf("unknown report format %q", format),
+		})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := report.Write(&buf, format, config, result); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"message": fmt.Sprintf("error: %s", err.Error()),
+		})
+		return
+	}
+	ctx.Data(http.StatusOK, report.ContentType(format), buf.Bytes())
+}
+
+// checkAPIConfig rejects the settings that read files, such as wordlists, as they would be read from the filesystem
+// of the server on behalf of the API client
+func checkAPIConfig(config race.Configuration) error {
+	for i, t := range config.Requests {
+		if t.Wordlist != "" || t.CSV != "" {
+			return fmt.Errorf("request #%d: wordlist and csv files can only be used from the command line", i+1)
+		}
+	}
+	return nil
+}
+
+// validReportFormat checks that a report format is supported
+func validReportFormat(format string) bool {
+	for _, f := range report.Formats {
+		if f == format {
+			return true
+		}
 	}
+	return false
+}
 
+// writeJSON sends v as the JSON response body. Responses are serialized manually, in order to remove html escaping
+// from the response bodies of the targets.
+func writeJSON(ctx *gin.Context, status int, v interface{}) {
 	// Set response values
 	ctx.Header("Content-Type", "application/json")
-	ctx.Status(http.StatusOK)
+	ctx.Status(status)
 
-	// Manually serialize responses, in order to remove html escaping.
 	enc := json.NewEncoder(ctx.Writer)
 	enc.SetEscapeHTML(false) // Disable html escaping
-	enc.Encode(responses)
+	enc.Encode(v)
 }
@@ -1,91 +1,390 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/TheHackerDev/race-the-web/race"
+	"github.com/TheHackerDev/race-the-web/report"
+	"github.com/fatih/color"
 	"github.com/naoina/toml"
 )
 
 // StartCMD begins the program with command-line usage.
-// Returns any errors encountered during operation.
-func StartCMD() error {
+// Returns the exit code for the outcome of the race test, and any errors that stopped it from running.
+func StartCMD() (int, error) {
+	reportFiles, err := checkOutputFlags()
+	if err != nil {
+		return exitConfigError, err
+	}
+
 	// Check the config file
-	configFile := os.Args[1]
-	var err error
-	configuration, err = getConfigFile(configFile)
+	configFile := flag.Arg(0)
+	config, err := getConfigFile(configFile)
 	if err != nil {
-		return err
+		return exitConfigError, err
+	}
+
+	// Import the requests taken from other files, relative to the config file
+	if err := race.ImportRequests(&config, filepath.Dir(configFile)); err != nil {
+		return exitConfigError, err
 	}
 
+	return runRace(config, reportFiles)
+}
+
+// checkOutputFlags checks the output format and the reports requested on the command line
+func checkOutputFlags() ([]reportFile, error) {
+	switch outputFormat {
+	case outputText, outputDiff:
+	case outputJSON, outputNDJSON:
+		// Keep standard output for the JSON, sending logs and errors to standard error
+		log.SetOutput(os.Stderr)
+		color.Output = os.Stderr
+	default:
+		return nil, fmt.Errorf("unknown output format %q", outputFormat)
+	}
+	return parseReports(reports)
+}
+
+// runRace runs the race test for the configuration, and outputs the result as requested on the command line.
+// Returns the exit code for the outcome of the race test, and any errors that stopped it from running.
+func runRace(config race.Configuration, reportFiles []reportFile) (int, error) {
 	// Set default values
-	SetDefaults(&configuration)
+	race.SetDefaults(&config)
 
-	// Run the race test
-	err, responseData := StartRace()
+	// Stop the race test on Ctrl-C, still reporting the responses received so far.
+	// A second Ctrl-C exits immediately.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		if _, ok := <-interrupt; ok {
+			log.Println("Interrupted, stopping the race test.")
+			signal.Stop(interrupt)
+			cancel()
+		}
+	}()
+	defer func() {
+		signal.Stop(interrupt)
+		close(interrupt)
+	}()
+
+	// Run the race test, keeping every response if they are to be saved or reported
+	runner := race.NewRunner(config)
+	runner.Record = saveDir != ""
+	for _, r := range reportFiles {
+		if report.Recorded(r.format) {
+			runner.Record = true
+		}
+	}
+	result, err := runner.Run(ctx)
 	if err != nil {
-		return err
+		return exitConfigError, err
 	}
 
 	// Output responses
-	outputResponses(responseData)
+	switch outputFormat {
+	case outputJSON:
+		outputJSONResult(result)
+	case outputNDJSON:
+		outputNDJSONResult(result)
+	case outputDiff:
+		outputErrors(result.Errors)
+		outputDiffs(config, result)
+		outputVerdict(result.Verdict)
+	default:
+		outputErrors(result.Errors)
+		outputResponses(config, result.Responses)
+		outputVerdict(result.Verdict)
+	}
+
+	// Write the reports
+	code := exitOK
+	for _, r := range reportFiles {
+		if err := writeReportFile(r, config, result); err != nil {
+			outError("[ERROR] could not write %s report: %s\n", r.format, err)
+			code = exitError
+		}
+	}
+
+	// Save the raw requests and responses
+	if saveDir != "" {
+		if err := report.SaveRaw(saveDir, config, result); err != nil {
+			outError("[ERROR] could not save the responses: %s\n", err)
+			code = exitError
+		}
+	}
+
+	// A race condition found, then a failed assertion, take precedence over failed requests, as what they found holds
+	// regardless
+	if outputFormat == outputText || outputFormat == outputDiff {
+		outputAssertions(result)
+	}
+	switch {
+	case result.Verdict != nil && result.Verdict.Vulnerable:
+		return exitVulnerable, nil
+	case len(result.AssertionFailures) > 0:
+		return exitAssertionFailed, nil
+	case result.ErrorsExceeded || result.Interrupted:
+		return exitErrorsExceeded, nil
+	}
+	return code, nil
+}
+
+// reportFile is a report requested on the command line
+type reportFile struct {
+	format string
+	path   string
+}
+
+// Function parseReports checks the reports requested on the command line, each given as "format:path".
+func parseReports(values []string) ([]reportFile, error) {
+	var files []reportFile
+	for _, value := range values {
+		split := strings.SplitN(value, ":", 2)
+		if len(split) != 2 || split[1] == "" {
+			return nil, fmt.Errorf("report %q must be given as format:path", value)
+		}
+		if !validReportFormat(split[0]) {
+			return nil, fmt.Errorf("unknown report format %q. Supported formats: %s", split[0], strings.Join(report.Formats, ", "))
+		}
+		files = append(files, reportFile{format: split[0], path: split[1]})
+	}
+	return files, nil
+}
 
-	return nil
+// Function writeReportFile writes a report of the result to its file.
+func writeReportFile(r reportFile, config race.Configuration, result *race.Result) error {
+	f, err := os.Create(r.path)
+	if err != nil {
+		return err
+	}
+	if err := report.Write(f, r.format, config, result); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
 }
 
 // Function getConfigFile checks that all necessary configuration fields are given
 // in a valid config file, and parses it for data.
 // Returns a Configuration object if successful.
 // Returns an empty Configuration object and a custom error if something went wrong.
-func getConfigFile(location string) (Configuration, error) {
+func getConfigFile(location string) (race.Configuration, error) {
 	f, err := os.Open(location)
 	if err != nil {
-		return Configuration{}, fmt.Errorf("could not open configuration file: %s", err.Error())
+		return race.Configuration{}, fmt.Errorf("could not open configuration file: %s", err.Error())
 	}
 	defer f.Close()
 
 	buf, err := ioutil.ReadAll(f)
 	if err != nil {
-		return Configuration{}, fmt.Errorf("could not read configuration file: %s", err.Error())
+		return race.Configuration{}, fmt.Errorf("could not read configuration file: %s", err.Error())
 	}
-	var config Configuration
+	var config race.Configuration
 	// Parse all data from the provided configuration file into a Configuration object
 	if err := toml.Unmarshal(buf, &config); err != nil {
-		return Configuration{}, fmt.Errorf("could not unmarshal TOML file: %s", err.Error())
+		return race.Configuration{}, fmt.Errorf("could not unmarshal TOML file: %s", err.Error())
 	}
 
 	return config, nil
 }
 
+// outputErrors logs the errors from individual requests to the command line
+func outputErrors(errors []error) {
+	for _, err := range errors {
+		outError("[ERROR] %s\n", err.Error())
+	}
+}
+
+// outputJSONResult writes the result to standard output as JSON, in the same structure as the results of API jobs
+func outputJSONResult(result *race.Result) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(newJobResult(result)); err != nil {
+		outError("[ERROR] could not write the result: %s\n", err)
+	}
+}
+
+// NDJSON records, told apart by their type
+type ndjsonResponse struct {
+	Type string `json:"type"` // "response"
+	race.UniqueResponseInfo
+}
+
+type ndjsonError struct {
+	Type  string `json:"type"` // "error"
+	Error string `json:"error"`
+}
+
+type ndjsonSummary struct {
+	Type        string        `json:"type"`   // "summary"
+	Unique      int           `json:"unique"` // Number of unique responses
+	Spread      time.Duration `json:"spread"`
+	Interrupted bool          `json:"interrupted"`
+	Baseline    int           `json:"baseline"`
+	Verdict     *race.Verdict `json:"verdict,omitempty"`
+
+	AssertionFailures []string `json:"assertion_failures"`
+	ErrorsExceeded    bool     `json:"errors_exceeded"`
+}
+
+// outputNDJSONResult writes the result to standard output as newline-delimited JSON: a record for each unique response,
+// then a record for each error, and finally a summary of the race
+func outputNDJSONResult(result *race.Result) {
+	jobResult := newJobResult(result)
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	var records []interface{}
+	for _, resp := range jobResult.Responses {
+		records = append(records, ndjsonResponse{Type: "response", UniqueResponseInfo: resp})
+	}
+	for _, err := range jobResult.Errors {
+		records = append(records, ndjsonError{Type: "error", Error: err})
+	}
+	records = append(records, ndjsonSummary{
+		Type:        "summary",
+		Unique:      len(jobResult.Responses),
+		Spread:      jobResult.Spread,
+		Interrupted: jobResult.Interrupted,
+		Baseline:    jobResult.Baseline,
+		Verdict:     jobResult.Verdict,
+
+		AssertionFailures: jobResult.AssertionFailures,
+		ErrorsExceeded:    jobResult.ErrorsExceeded,
+	})
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			outError("[ERROR] could not write the result: %s\n", err)
+			return
+		}
+	}
+}
+
 // outputResponses logs the response data to the command line
-func outputResponses(uniqueResponses []UniqueResponseInfo) {
+func outputResponses(config race.Configuration, uniqueResponses []race.UniqueResponseInfo) {
 	fmt.Printf("Unique Responses:\n\n")
-	for _, data := range uniqueResponses {
+	for i, data := range uniqueResponses {
+		outputResponse(config, i, data)
+	}
+
+	// Show whether the requests overlapped, numbering rows after the unique responses above
+	if timeline := race.RenderTimeline(uniqueResponses, 60, 40); timeline != "" {
 		fmt.Println("**************************************************")
-		fmt.Printf("RESPONSE:\n")
-		fmt.Printf("[Status Code] %v\n", data.Response.StatusCode)
-		fmt.Printf("[Protocol] %v\n", data.Response.Protocol)
-		if len(data.Response.Headers) != 0 {
-			fmt.Println("[Headers]")
-			for header, value := range data.Response.Headers {
-				fmt.Printf("\t%v: %v\n", header, value)
-			}
+		fmt.Printf("TIMELINE:\n")
+		fmt.Printf("('>' sending, '.' waiting, '=' receiving)\n")
+		fmt.Print(timeline)
+	}
+}
+
+// outputResponse logs the data of the unique response at index i to the command line
+func outputResponse(config race.Configuration, i int, data race.UniqueResponseInfo) {
+	fmt.Println("**************************************************")
+	fmt.Printf("RESPONSE #%d:\n", i+1)
+	fmt.Printf("[Status Code] %v\n", data.Response.StatusCode)
+	fmt.Printf("[Protocol] %v\n", data.Response.Protocol)
+	if len(data.Response.Headers) != 0 {
+		fmt.Println("[Headers]")
+		for header, value := range data.Response.Headers {
+			fmt.Printf("\t%v: %v\n", header, value)
 		}
-		fmt.Printf("[Location] %v\n", data.Response.Location)
-		fmt.Printf("[Body]\n%s\n", data.Response.Body)
-		fmt.Printf("Similar: %v\n", data.Count-1)
-		fmt.Printf("REQUESTS:\n")
-		for _, target := range data.Targets {
-			fmt.Printf("\tURL: %s\n", target.URL)
-			fmt.Printf("\tMethod: %s\n", target.Method)
-			fmt.Printf("\tBody: %s\n", target.Body)
-			fmt.Printf("\tCookies: %v\n", target.Cookies)
-			if configuration.Proxy != "" {
-				fmt.Printf("\tProxy: %v\n", configuration.Proxy)
+	}
+	fmt.Printf("[Location] %v\n", data.Response.Location)
+	fmt.Printf("[Body]\n%s\n", data.Response.Body)
+	fmt.Printf("[Timing] (min / median / max)\n")
+	fmt.Printf("\tReleased: +%v\n", data.Timing.StartOffset)
+	fmt.Printf("\tLast byte sent: +%v\n", data.Timing.LastByteOffset)
+	fmt.Printf("\tTime to first byte: %v\n", data.Timing.FirstByte)
+	fmt.Printf("\tTotal: %v\n", data.Timing.Total)
+	fmt.Printf("Similar: %v\n", data.Count-1)
+	if data.Successes > 0 {
+		fmt.Printf("Successes: %v\n", data.Successes)
+	}
+	if len(data.Outliers) > 0 {
+		fmt.Println("[Outliers]")
+		for _, outlier := range data.Outliers {
+			fmt.Printf("\t%d response(s), %.0f%% similar:\n", outlier.Count, outlier.Similarity*100)
+			for _, line := range strings.Split(strings.TrimSuffix(outlier.Diff, "\n"), "\n") {
+				fmt.Printf("\t\t%s\n", line)
 			}
-			fmt.Printf("\tRedirects: %t\n", target.Redirects)
-			fmt.Println()
 		}
+		if data.MoreOutliers > 0 {
+			fmt.Printf("\t%d more response(s)\n", data.MoreOutliers)
+		}
+	}
+	fmt.Printf("REQUESTS:\n")
+	for _, target := range data.Targets {
+		fmt.Printf("\tURL: %s\n", target.URL)
+		fmt.Printf("\tMethod: %s\n", target.Method)
+		fmt.Printf("\tBody: %s\n", target.Body)
+		fmt.Printf("\tCookies: %v\n", target.Cookies)
+		if config.Proxy != "" {
+			fmt.Printf("\tProxy: %v\n", config.Proxy)
+		}
+		fmt.Printf("\tRedirects: %t\n", target.Redirects)
+		fmt.Println()
+	}
+}
+
+// outputDiffs logs the baseline response in full, followed by a diff of every other unique response against it
+func outputDiffs(config race.Configuration, result *race.Result) {
+	fmt.Printf("Unique Responses: %d\n\n", len(result.Responses))
+	if len(result.Responses) == 0 {
+		return
+	}
+
+	fmt.Printf("BASELINE:\n")
+	outputResponse(config, result.Baseline, result.Responses[result.Baseline])
+	for i, data := range result.Responses {
+		if i == result.Baseline {
+			continue
+		}
+		fmt.Println("**************************************************")
+		fmt.Printf("RESPONSE #%d (%d received):\n", i+1, data.Count)
+		fmt.Print(data.Diff)
+	}
+}
+
+// outputVerdict logs whether the race condition was exploited, and the successes observed for each request
+func outputVerdict(verdict *race.Verdict) {
+	if verdict == nil {
+		return
+	}
+	fmt.Println("**************************************************")
+	switch {
+	case verdict.Vulnerable:
+		outVulnerable("VERDICT: %s\n", verdict)
+	case verdict.Inconclusive:
+		outInconclusive("VERDICT: %s\n", verdict)
+	default:
+		outNotVulnerable("VERDICT: %s\n", verdict)
+	}
+	for _, count := range verdict.Requests {
+		fmt.Printf("\t%s %s: %d success(es)", count.Method, count.URL, count.Successes)
+		if count.Max != nil {
+			fmt.Printf(", expected at most %d", *count.Max)
+		}
+		fmt.Println()
+	}
+}
+
+// outputAssertions logs the assertions that failed, and whether too many requests failed
+func outputAssertions(result *race.Result) {
+	for _, failure := range result.AssertionFailures {
+		outError("[ASSERTION FAILED] %s\n", failure)
+	}
+	if result.ErrorsExceeded {
+		outError("[ASSERTION FAILED] %d requests failed, more than allowed\n", result.Failed)
 	}
 }
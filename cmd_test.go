@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/TheHackerDev/race-the-web/race"
+)
+
+// Function runQuietly runs the race test with JSON output, returning the exit code and what was written to
+// standard output.
+func runQuietly(t *testing.T, config race.Configuration) (int, string) {
+	out, err := ioutil.TempFile("", "race-output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	stdout, format := os.Stdout, outputFormat
+	os.Stdout, outputFormat = out, outputJSON
+	code, err := runRace(config, nil)
+	os.Stdout, outputFormat = stdout, format
+	if err != nil {
+		t.Fatalf("runRace returned error: %v", err)
+	}
+
+	written, err := ioutil.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code, string(written)
+}
+
+func TestRunRaceInterrupted(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	zero := 0
+	tests := []struct {
+		name   string
+		assert *race.Assertions
+	}{
+		{"no assertions", nil},
+		{"no errors allowed", &race.Assertions{MaxErrors: &zero}},
+	}
+	for _, test := range tests {
+		config := race.Configuration{
+			Count:    2,
+			Timeout:  1,
+			Assert:   test.assert,
+			Requests: []race.Request{{Method: "GET", URL: server.URL}},
+		}
+		if code, _ := runQuietly(t, config); code != exitErrorsExceeded {
+			t.Errorf("%s: a race that timed out exited with code %d, want %d", test.name, code, exitErrorsExceeded)
+		}
+	}
+}
+
+func TestOutputJSONResult(t *testing.T) {
+	// Every request fails, so that no response is received
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	config := race.Configuration{
+		Count:    2,
+		Requests: []race.Request{{Method: "GET", URL: server.URL}},
+	}
+	_, out := runQuietly(t, config)
+	var result map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("output %q is not JSON: %v", out, err)
+	}
+	for field, want := range map[string]string{"responses": "[]", "assertion_failures": "[]"} {
+		if string(result[field]) != want {
+			t.Errorf("%s encoded as %s, want %s", field, result[field], want)
+		}
+	}
+	var errors []string
+	if err := json.Unmarshal(result["errors"], &errors); err != nil || len(errors) != 2 {
+		t.Errorf("errors encoded as %s, want the 2 requests that failed", result["errors"])
+	}
+}
@@ -0,0 +1,487 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/TheHackerDev/race-the-web/race"
+)
+
+// curlIgnored are the curl options that make no difference to a race test, as they only change how curl itself
+// behaves. The HTTP client of the race already decompresses responses, and does not verify certificates.
+var curlIgnored = map[string]bool{
+	"-s": true, "--silent": true, "-S": true, "--show-error": true, "-i": true, "--include": true,
+	"-v": true, "--verbose": true, "--compressed": true, "-k": true, "--insecure": true,
+	"-f": true, "--fail": true, "-N": true, "--no-buffer": true, "-g": true, "--globoff": true,
+	"--http1.1": true, "--path-as-is": true,
+}
+
+// curlIgnoredWithValue are ignored like curlIgnored, but take a value.
+var curlIgnoredWithValue = map[string]bool{
+	"-o": true, "--output": true, "--connect-timeout": true, "-w": true, "--write-out": true,
+}
+
+// curlShortWithValue are the short options that take a value, which may be attached to them, as in -XPOST.
+var curlShortWithValue = map[byte]bool{
+	'X': true, 'H': true, 'b': true, 'd': true, 'x': true, 'A': true, 'e': true, 'u': true, 'm': true, 'o': true, 'w': true,
+}
+
+// StartFromCurl converts a curl command line, such as one copied from a browser's developer tools, into a request.
+// It prints the configuration as TOML, or runs the race test with it if asked to.
+// Returns the exit code for the outcome, and any errors that stopped it from running.
+func StartFromCurl(args []string) (int, error) {
+	flags := flag.NewFlagSet("from-curl", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	count := flags.Int("count", 100, "Number of requests to send")
+	run := flags.Bool("run", false, "Run the race test, instead of printing its configuration")
+	if err := flags.Parse(args); err != nil {
+		return exitConfigError, err
+	}
+
+	// The command is given as separate arguments, as a single quoted argument, or on standard input
+	words := flags.Args()
+	if len(words) <= 1 {
+		command := strings.Join(words, "")
+		if len(words) == 0 {
+			b, err := ioutil.ReadAll(os.Stdin)
+			if err != nil {
+				return exitConfigError, fmt.Errorf("could not read the curl command: %v", err)
+			}
+			command = string(b)
+		}
+		var err error
+		if words, err = splitShellWords(command); err != nil {
+			return exitConfigError, err
+		}
+	}
+	if len(words) > 0 && words[0] == "curl" {
+		words = words[1:]
+	}
+
+	config, err := parseCurl(words)
+	if err != nil {
+		return exitConfigError, err
+	}
+	config.Count = *count
+
+	if *run {
+		reportFiles, err := checkOutputFlags()
+		if err != nil {
+			return exitConfigError, err
+		}
+		return runRace(config, reportFiles)
+	}
+	fmt.Print(curlTOML(config))
+	return exitOK, nil
+}
+
+// Function parseCurl converts the arguments of a curl command into a configuration with a single request.
+// Options that have no effect on a race test are ignored, while options that are not understood are an error,
+// so that the request is not raced without them.
+func parseCurl(args []string) (race.Configuration, error) {
+	var config race.Configuration
+	var t race.Request
+	var data []string
+	get, head := false, false
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		option, value, hasValue := arg, "", false
+
+		// Split attached values from short options, and expand groups of short options such as -sSL
+		if len(arg) > 2 && arg[0] == '-' && arg[1] != '-' {
+			if curlShortWithValue[arg[1]] {
+				option, value, hasValue = arg[:2], arg[2:], true
+			} else {
+				var expanded []string
+				for _, c := range arg[1:] {
+					expanded = append(expanded, "-"+string(c))
+				}
+				args = append(args[:i], append(expanded, args[i+1:]...)...)
+				i--
+				continue
+			}
+		}
+		next := func() (string, error) {
+			if hasValue {
+				return value, nil
+			}
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("curl option %s needs a value", option)
+			}
+			i++
+			return args[i], nil
+		}
+
+		var err error
+		switch option {
+		case "-X", "--request":
+			t.Method, err = next()
+		case "-H", "--header":
+			var header string
+			if header, err = next(); err != nil {
+				break
+			}
+			split := strings.SplitN(header, ":", 2)
+			if len(split) != 2 {
+				return config, fmt.Errorf("invalid header %q", header)
+			}
+			name, headerValue := strings.TrimSpace(split[0]), strings.TrimSpace(split[1])
+			switch {
+			case http.CanonicalHeaderKey(name) == "Cookie":
+				t.Cookies = append(t.Cookies, splitCookies(headerValue)...)
+			case race.ClientHeader(name):
+				// Headers such as Accept-Encoding are left for the HTTP client to set, as in raw requests
+			default:
+				t.Headers = append(t.Headers, fmt.Sprintf("%s: %s", name, headerValue))
+			}
+		case "-b", "--cookie":
+			var cookies string
+			if cookies, err = next(); err != nil {
+				break
+			}
+			if !strings.Contains(cookies, "=") {
+				return config, fmt.Errorf("cookie files are not supported: %s", cookies)
+			}
+			t.Cookies = append(t.Cookies, splitCookies(cookies)...)
+		case "-d", "--data", "--data-ascii", "--data-binary", "--data-raw", "--data-urlencode":
+			var d string
+			if d, err = next(); err != nil {
+				break
+			}
+			if d, err = curlData(option, d); err == nil {
+				data = append(data, d)
+			}
+		case "-G", "--get":
+			get = true
+		case "-I", "--head":
+			head = true
+		case "-L", "--location":
+			t.Redirects = true
+		case "--http2", "--http2-prior-knowledge":
+			t.HTTP2 = true
+		case "-x", "--proxy":
+			config.Proxy, err = next()
+		case "-A", "--user-agent":
+			var agent string
+			if agent, err = next(); err == nil {
+				t.Headers = append(t.Headers, "User-Agent: "+agent)
+			}
+		case "-e", "--referer":
+			var referer string
+			if referer, err = next(); err == nil {
+				t.Headers = append(t.Headers, "Referer: "+referer)
+			}
+		case "-u", "--user":
+			var user string
+			if user, err = next(); err == nil {
+				t.Headers = append(t.Headers, "Authorization: Basic "+base64.StdEncoding.EncodeToString([]byte(user)))
+			}
+		case "-m", "--max-time":
+			var seconds string
+			if seconds, err = next(); err != nil {
+				break
+			}
+			var timeout float64
+			if timeout, err = strconv.ParseFloat(seconds, 64); err == nil {
+				t.Timeout = int(math.Ceil(timeout))
+			}
+		case "--url":
+			t.URL, err = next()
+		default:
+			switch {
+			case curlIgnored[option]:
+			case curlIgnoredWithValue[option]:
+				_, err = next()
+			case strings.HasPrefix(arg, "-"):
+				return config, fmt.Errorf("unsupported curl option %s", arg)
+			case t.URL == "":
+				t.URL = arg
+			default:
+				return config, fmt.Errorf("more than one URL given: %s", arg)
+			}
+		}
+		if err != nil {
+			return config, err
+		}
+	}
+
+	if t.URL == "" {
+		return config, fmt.Errorf("no URL given in the curl command")
+	}
+	// curl assumes http for URLs without a scheme
+	if !strings.Contains(t.URL, "://") {
+		t.URL = "http://" + t.URL
+	}
+	if _, err := url.Parse(t.URL); err != nil {
+		return config, fmt.Errorf("invalid URL %q: %v", t.URL, err)
+	}
+
+	// Data is sent in the body, or in the query string with -G
+	body := strings.Join(data, "&")
+	switch {
+	case get && body != "":
+		separator := "?"
+		if strings.Contains(t.URL, "?") {
+			separator = "&"
+		}
+		t.URL += separator + body
+	case body != "":
+		t.Body = body
+	}
+	if t.Method == "" {
+		switch {
+		case head:
+			t.Method = "HEAD"
+		case t.Body != "":
+			t.Method = "POST"
+		default:
+			t.Method = "GET"
+		}
+	}
+
+	config.Requests = []race.Request{t}
+	return config, nil
+}
+
+// Function curlData returns the data of a curl data option as it is sent. Data starting with @ is read from a file,
+// except with --data-raw. --data-urlencode encodes the content, after the name and = if there is one.
+func curlData(option, data string) (string, error) {
+	readFile := func(name string) (string, error) {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			return "", fmt.Errorf("could not read data file: %v", err)
+		}
+		return string(b), nil
+	}
+
+	switch option {
+	case "--data-raw":
+		return data, nil
+	case "--data-urlencode":
+		name, content := "", data
+		if i := strings.IndexAny(data, "=@"); i >= 0 {
+			name, content = data[:i], data[i+1:]
+			if data[i] == '@' {
+				var err error
+				if content, err = readFile(content); err != nil {
+					return "", err
+				}
+			}
+			if name == "" && data[i] == '=' {
+				return url.QueryEscape(content), nil
+			}
+		}
+		if name == "" {
+			return url.QueryEscape(content), nil
+		}
+		return name + "=" + url.QueryEscape(content), nil
+	}
+
+	if !strings.HasPrefix(data, "@") {
+		return data, nil
+	}
+	content, err := readFile(data[1:])
+	if err != nil {
+		return "", err
+	}
+	// Like curl, only --data-binary keeps the line breaks of a file
+	if option != "--data-binary" {
+		content = strings.NewReplacer("\r", "", "\n", "").Replace(content)
+	}
+	return content, nil
+}
+
+// Function splitCookies splits a Cookie header value into its name=value pairs.
+func splitCookies(header string) []string {
+	var cookies []string
+	for _, cookie := range strings.Split(header, ";") {
+		if cookie = strings.TrimSpace(cookie); cookie != "" {
+			cookies = append(cookies, cookie)
+		}
+	}
+	return cookies
+}
+
+// Function splitShellWords splits a command line into words as a POSIX shell would, with single quotes, double
+// quotes, backslash escapes and line continuations, and the $'...' strings that browsers copy commands with.
+func splitShellWords(command string) ([]string, error) {
+	var words []string
+	var word bytes.Buffer
+	inWord := false
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '\\':
+			if i+1 < len(command) {
+				i++
+				// Line continuations are removed, without starting a word
+				if command[i] == '\n' {
+					continue
+				}
+				if command[i] == '\r' && i+1 < len(command) && command[i+1] == '\n' {
+					i++
+					continue
+				}
+				word.WriteByte(command[i])
+			}
+			inWord = true
+		case c == '\'':
+			inWord = true
+			end := strings.IndexByte(command[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote in the curl command")
+			}
+			word.WriteString(command[i+1 : i+1+end])
+			i += end + 1
+		case c == '$' && i+1 < len(command) && command[i+1] == '\'':
+			inWord = true
+			n, err := readANSIString(command[i+2:], &word)
+			if err != nil {
+				return nil, err
+			}
+			i += n + 1
+		case c == '"':
+			inWord = true
+			closed := false
+			for i++; i < len(command); i++ {
+				if command[i] == '"' {
+					closed = true
+					break
+				}
+				// Within double quotes, a backslash only escapes these characters
+				if command[i] == '\\' && i+1 < len(command) && strings.IndexByte("\"\\$`\n", command[i+1]) >= 0 {
+					i++
+					if command[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteByte(command[i])
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated double quote in the curl command")
+			}
+		default:
+			inWord = true
+			word.WriteByte(c)
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// Function readANSIString reads the content of a $'...' string up to its closing quote, decoding its escapes
+// into word. Returns the number of bytes read, including the closing quote.
+func readANSIString(s string, word *bytes.Buffer) (int, error) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\'' {
+			return i + 1, nil
+		}
+		if c != '\\' || i+1 >= len(s) {
+			word.WriteByte(c)
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			word.WriteByte('\n')
+		case 'r':
+			word.WriteByte('\r')
+		case 't':
+			word.WriteByte('\t')
+		case 'x', 'u', 'U':
+			// Hexadecimal bytes, or Unicode code points
+			digits := map[byte]int{'x': 2, 'u': 4, 'U': 8}[s[i]]
+			end := i + 1
+			for end < len(s) && end < i+1+digits && strings.IndexByte("0123456789abcdefABCDEF", s[end]) >= 0 {
+				end++
+			}
+			n, err := strconv.ParseUint(s[i+1:end], 16, 32)
+			if err != nil {
+				return 0, fmt.Errorf("invalid escape \\%s in the curl command", s[i:end])
+			}
+			if s[i] == 'x' {
+				word.WriteByte(byte(n))
+			} else {
+				var b [utf8.UTFMax]byte
+				word.Write(b[:utf8.EncodeRune(b[:], rune(n))])
+			}
+			i = end - 1
+		default:
+			// Quotes, backslashes and anything else stand for themselves
+			word.WriteByte(s[i])
+		}
+	}
+	return 0, fmt.Errorf("unterminated $' quote in the curl command")
+}
+
+// Function curlTOML formats the configuration as a config file, in the same layout as the sample configuration.
+func curlTOML(config race.Configuration) string {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "# Send %d requests to the target\ncount = %d\n", config.Count, config.Count)
+	if config.Proxy != "" {
+		fmt.Fprintf(&out, "proxy = %s\n", tomlString(config.Proxy))
+	}
+	for _, t := range config.Requests {
+		fmt.Fprintf(&out, "\n[[requests]]\n")
+		fmt.Fprintf(&out, "    method = %s\n", tomlString(t.Method))
+		fmt.Fprintf(&out, "    url = %s\n", tomlString(t.URL))
+		if t.Body != "" {
+			fmt.Fprintf(&out, "    body = %s\n", tomlString(t.Body))
+		}
+		if len(t.Cookies) > 0 {
+			fmt.Fprintf(&out, "    cookies = %s\n", tomlStrings(t.Cookies))
+		}
+		if len(t.Headers) > 0 {
+			fmt.Fprintf(&out, "    headers = %s\n", tomlStrings(t.Headers))
+		}
+		if t.Redirects {
+			fmt.Fprintf(&out, "    redirects = true\n")
+		}
+		if t.HTTP2 {
+			fmt.Fprintf(&out, "    http2 = true\n")
+		}
+		if t.Timeout > 0 {
+			fmt.Fprintf(&out, "    timeout = %d\n", t.Timeout)
+		}
+	}
+	return out.String()
+}
+
+// Function tomlString quotes a string for TOML. The escapes of JSON strings are all valid in TOML.
+func tomlString(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// Function tomlStrings formats an array of strings for TOML.
+func tomlStrings(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = tomlString(value)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitShellWords(t *testing.T) {
+	tests := []struct {
+		command string
+		words   []string
+	}{
+		{`curl http://h/`, []string{"curl", "http://h/"}},
+		{`curl 'http://h/a b' -H "X: \"y\""`, []string{"curl", "http://h/a b", "-H", `X: "y"`}},
+		{"curl http://h/ \\\n  -X PUT", []string{"curl", "http://h/", "-X", "PUT"}},
+		{`curl $'http://h/' -X PUT`, []string{"curl", "http://h/", "-X", "PUT"}},
+		{`--data-raw $'a=1\nb' --compressed`, []string{"--data-raw", "a=1\nb", "--compressed"}},
+		{`-d $'it\'s' -k`, []string{"-d", "it's", "-k"}},
+		{`-d $'\x41é'`, []string{"-d", "Aé"}},
+		{`-d $'a'$'b'c`, []string{"-d", "abc"}},
+		{`a\ b`, []string{"a b"}},
+		{`''`, []string{""}},
+	}
+	for _, test := range tests {
+		words, err := splitShellWords(test.command)
+		if err != nil {
+			t.Errorf("splitShellWords(%q) returned error: %v", test.command, err)
+			continue
+		}
+		if !reflect.DeepEqual(words, test.words) {
+			t.Errorf("splitShellWords(%q) = %q, want %q", test.command, words, test.words)
+		}
+	}
+
+	for _, command := range []string{`'a`, `"a`, `$'a`} {
+		if _, err := splitShellWords(command); err == nil {
+			t.Errorf("splitShellWords(%q) returned no error", command)
+		}
+	}
+}
+
+func TestParseCurl(t *testing.T) {
+	tests := []struct {
+		command string
+		method  string
+		url     string
+		body    string
+		headers []string
+		cookies []string
+	}{
+		{
+			command: `curl 'http://h/'`,
+			method:  "GET",
+			url:     "http://h/",
+		},
+		{
+			command: `curl $'http://h/' -X PUT`,
+			method:  "PUT",
+			url:     "http://h/",
+		},
+		{
+			command: `curl 'https://h/pay' -H 'accept-encoding: gzip, deflate, br' -H 'X-A: b' -H 'cookie: a=1; b=2' --data-raw $'a=1\nb' --compressed`,
+			method:  "POST",
+			url:     "https://h/pay",
+			body:    "a=1\nb",
+			headers: []string{"X-A: b"},
+			cookies: []string{"a=1", "b=2"},
+		},
+		{
+			command: `curl -sSL -XDELETE h/item -b 'c=3'`,
+			method:  "DELETE",
+			url:     "http://h/item",
+			cookies: []string{"c=3"},
+		},
+		{
+			command: `curl -G 'http://h/s?x=1' -d q=1 --data-urlencode 'n=a b'`,
+			method:  "GET",
+			url:     "http://h/s?x=1&q=1&n=a+b",
+		},
+		{
+			command: `curl -I http://h/ -u user:pass`,
+			method:  "HEAD",
+			url:     "http://h/",
+			headers: []string{"Authorization: Basic dXNlcjpwYXNz"},
+		},
+	}
+	for _, test := range tests {
+		words, err := splitShellWords(test.command)
+		if err != nil {
+			t.Fatalf("splitShellWords(%q) returned error: %v", test.command, err)
+		}
+		config, err := parseCurl(words[1:])
+		if err != nil {
+			t.Errorf("parseCurl(%q) returned error: %v", test.command, err)
+			continue
+		}
+		req := config.Requests[0]
+		if req.Method != test.method || req.URL != test.url || req.Body != test.body ||
+			!reflect.DeepEqual(req.Headers, test.headers) || !reflect.DeepEqual(req.Cookies, test.cookies) {
+			t.Errorf("parseCurl(%q) = %s %s %q, headers %q, cookies %q; want %s %s %q, headers %q, cookies %q", test.command,
+				req.Method, req.URL, req.Body, req.Headers, req.Cookies,
+				test.method, test.url, test.body, test.headers, test.cookies)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"http://h/", "--unknown"},
+		{"http://h/", "http://other/"},
+		{"-X"},
+		{"-b", "cookies.txt", "http://h/"},
+		{"-X", "GET"},
+	} {
+		if _, err := parseCurl(args); err == nil {
+			t.Errorf("parseCurl(%q) returned no error", args)
+		}
+	}
+}
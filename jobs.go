@@ -0,0 +1,410 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/TheHackerDev/race-the-web/race"
+	"github.com/TheHackerDev/race-the-web/report"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// Job states
+const (
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobCancelled = "cancelled"
+	JobFailed    = "failed"
+)
+
+// Job is a race test started through the API, which runs in the background.
+type Job struct {
+	ID       string             `json:"id"`
+	Status   string             `json:"status"`
+	Created  time.Time          `json:"created"`
+	Finished *time.Time         `json:"finished,omitempty"`
+	Config   race.Configuration `json:"config"` // Copy of the configuration, unaffected by later changes
+	Error    string             `json:"error,omitempty"`
+	Result   *JobResult         `json:"result,omitempty"`
+	Record   bool               `json:"record,omitempty"` // Every response is kept, for reports such as HAR
+
+	cancel context.CancelFunc
+	result *race.Result  // Result of the race, for reports
+	events []race.Event  // Progress events reported so far
+	notify chan struct{} // Closed when an event is added, or the job finishes
+}
+
+// JobResult is the JSON representation of a race.Result.
+type JobResult struct {
+	Responses   []race.UniqueResponseInfo `json:"responses"`
+	Errors      []string                  `json:"errors"`
+	Spread      time.Duration             `json:"spread"`
+	Interrupted bool                      `json:"interrupted"`
+	Baseline    int                       `json:"baseline"` // Index of the response that the others are diffed against
+	Verdict     *race.Verdict             `json:"verdict,omitempty"`
+
+	AssertionFailures []string `json:"assertion_failures"`
+	ErrorsExceeded    bool     `json:"errors_exceeded"`
+}
+
+// newJobResult converts a race result, whose errors would otherwise be lost in JSON.
+func newJobResult(result *race.Result) *JobResult {
+	jobResult := &JobResult{
+		Responses:   append([]race.UniqueResponseInfo{}, result.Responses...),
+		Errors:      []string{},
+		Spread:      result.Spread,
+		Interrupted: result.Interrupted,
+		Baseline:    result.Baseline,
+		Verdict:     result.Verdict,
+
+		AssertionFailures: []string{},
+		ErrorsExceeded:    result.ErrorsExceeded,
+	}
+	for _, err := range result.Errors {
+		jobResult.Errors = append(jobResult.Errors, err.Error())
+	}
+	for _, failure := range result.AssertionFailures {
+		jobResult.AssertionFailures = append(jobResult.AssertionFailures, failure.Message)
+	}
+	return jobResult
+}
+
+// Finished jobs are kept for jobTTL, and only the most recent maxFinishedJobs of them, so that a long-running
+// server does not keep every result and event. Running jobs are always kept.
+const (
+	jobTTL          = time.Hour
+	maxFinishedJobs = 100
+)
+
+// jobStore holds every job started through the API, until it is evicted or deleted.
+type jobStore struct {
+	sync.Mutex
+	jobs map[string]*Job
+}
+
+// jobs are the jobs started through the API
+var jobs = &jobStore{jobs: make(map[string]*Job)}
+
+// start runs a race for the configuration in the background, returning a snapshot of the new job.
+func (s *jobStore) start(config race.Configuration, record bool) (Job, error) {
+	id, err := newJobID()
+	if err != nil {
+		return Job{}, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &Job{
+		ID:      id,
+		Status:  JobRunning,
+		Created: time.Now(),
+		Config:  config,
+		Record:  record,
+		cancel:  cancel,
+		notify:  make(chan struct{}),
+	}
+
+	runner := race.NewRunner(config)
+	runner.Record = record
+	runner.Events = func(event race.Event) {
+		s.Lock()
+		defer s.Unlock()
+		job.events = append(job.events, event)
+		job.wake()
+	}
+
+	s.Lock()
+	s.evict(job.Created)
+	s.jobs[id] = job
+	snapshot := *job
+	s.Unlock()
+
+	go func() {
+		defer cancel()
+		result, err := runner.Run(ctx)
+
+		s.Lock()
+		defer s.Unlock()
+		finished := time.Now()
+		job.Finished = &finished
+		defer job.wake()
+		switch {
+		case err != nil:
+			job.Status = JobFailed
+			job.Error = err.Error()
+		case ctx.Err() != nil:
+			job.Status = JobCancelled
+			job.Result = newJobResult(result)
+			job.result = result
+		default:
+			job.Status = JobCompleted
+			job.Result = newJobResult(result)
+			job.result = result
+		}
+	}()
+
+	return snapshot, nil
+}
+
+// evict deletes the finished jobs that have expired by now, and the oldest finished jobs beyond maxFinishedJobs.
+// The store must be locked.
+func (s *jobStore) evict(now time.Time) {
+	var finished []*Job
+	for id, job := range s.jobs {
+		switch {
+		case job.Finished == nil:
+		case now.Sub(*job.Finished) > jobTTL:
+			delete(s.jobs, id)
+		default:
+			finished = append(finished, job)
+		}
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].Finished.Before(*finished[j].Finished)
+	})
+	for _, job := range finished[:len(finished)-maxFinishedJobs] {
+		delete(s.jobs, job.ID)
+	}
+}
+
+// get returns a snapshot of the job, and whether it exists.
+func (s *jobStore) get(id string) (Job, bool) {
+	s.Lock()
+	defer s.Unlock()
+	s.evict(time.Now())
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// list returns a snapshot of every job, oldest first. Results are left out, to keep the listing small.
+func (s *jobStore) list() []Job {
+	s.Lock()
+	defer s.Unlock()
+	s.evict(time.Now())
+	list := make([]Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		snapshot := *job
+		snapshot.Result = nil
+		list = append(list, snapshot)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Created.Before(list[j].Created)
+	})
+	return list
+}
+
+// events returns the events of the job from index from onwards, the status of the job when they were taken,
+// and a channel that is closed once there is more to read. Returns false if the job does not exist.
+func (s *jobStore) events(id string, from int) (events []race.Event, status string, more <-chan struct{}, ok bool) {
+	s.Lock()
+	defer s.Unlock()
+	s.evict(time.Now())
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, "", nil, false
+	}
+	if from < len(job.events) {
+		events = append(events, job.events[from:]...)
+	}
+	return events, job.Status, job.notify, true
+}
+
+// wake notifies anyone waiting on the job's events. The store must be locked.
+func (job *Job) wake() {
+	close(job.notify)
+	job.notify = make(chan struct{})
+}
+
+// remove cancels the job if it is still running, or deletes it from the store otherwise.
+// Returns a snapshot of the job, and whether it exists.
+func (s *jobStore) remove(id string) (Job, bool) {
+	s.Lock()
+	defer s.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	if job.Status == JobRunning {
+		job.cancel()
+	} else {
+		delete(s.jobs, id)
+	}
+	return *job, true
+}
+
+// API endpoint to stream the progress of a job as server-sent events. Every race.Event is sent with its type as the
+// event name, followed by a final "done" event with the status of the job once it has finished. Events already
+// reported are replayed first, so clients may connect at any time, and resume using the Last-Event-ID header.
+func StreamJobEvents(ctx *gin.Context) {
+	id := ctx.Param("id")
+	if _, ok := jobs.get(id); !ok {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"message": "job not found",
+		})
+		return
+	}
+
+	// Resume after the last event received by the client, if given
+	next := 0
+	if lastID, err := strconv.Atoi(ctx.Request.Header.Get("Last-Event-ID")); err == nil && lastID >= 0 {
+		next = lastID + 1
+	}
+
+	ctx.Header("Cache-Control", "no-cache")
+	clientGone := ctx.Request.Context().Done()
+	ctx.Stream(func(w io.Writer) bool {
+		events, status, more, ok := jobs.events(id, next)
+		if !ok {
+			// The job was deleted
+			return false
+		}
+		for _, event := range events {
+			ctx.Render(-1, sse.Event{
+				Id:    strconv.Itoa(next),
+				Event: event.Type,
+				Data:  event,
+			})
+			next++
+		}
+		if status != JobRunning {
+			ctx.SSEvent("done", gin.H{
+				"id":     id,
+				"status": status,
+			})
+			return false
+		}
+
+		// Send the events rendered so far, as they would otherwise be buffered until more arrive, then wait for more
+		ctx.Writer.Flush()
+		select {
+		case <-more:
+			return true
+		case <-clientGone:
+			return false
+		}
+	})
+}
+
+// newJobID returns a random job identifier.
+func newJobID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// API endpoint to start a race test in the background. The configuration is taken from the request body, or copied
+// from the configuration set through /set/config if no body is sent.
+func StartJob(ctx *gin.Context) {
+	// The saved configuration is copied, so that the job is unaffected by later changes
+	config := savedConfig()
+	if ctx.Request.ContentLength != 0 {
+		config = race.Configuration{} // Do not merge into the saved configuration
+		if ctx.BindJSON(&config) != nil {
+			// Invalid JSON sent
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"message": "invalid JSON data",
+			})
+			return
+		}
+		if err := checkAPIConfig(config); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+		race.SetDefaults(&config)
+	}
+
+	// Verify that config is present
+	if len(config.Requests) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"message": "no configuration set",
+		})
+		return
+	}
+
+	job, err := jobs.start(config, ctx.Query("record") == "true")
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"message": "could not start job: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, gin.H{
+		"id":     job.ID,
+		"status": job.Status,
+	})
+}
+
+// API endpoint to list all jobs, without their results
+func ListJobs(ctx *gin.Context) {
+	writeJSON(ctx, http.StatusOK, jobs.list())
+}
+
+// API endpoint to retrieve the status of a job, and its results once it has finished.
+// The results are sent as a report instead if a format is given, such as ?format=junit.
+func GetJob(ctx *gin.Context) {
+	job, ok := jobs.get(ctx.Param("id"))
+	if !ok {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"message": "job not found",
+		})
+		return
+	}
+
+	if format := ctx.Query("format"); format != "" && format != "json" {
+		if job.result == nil {
+			ctx.JSON(http.StatusConflict, gin.H{
+				"message": fmt.Sprintf("job is %s, and has no results", job.Status),
+			})
+			return
+		}
+		if report.Recorded(format) && !job.Record {
+			ctx.JSON(http.StatusConflict, gin.H{
+				"message": fmt.Sprintf("%s reports need every response, so the job must be started with ?record=true", format),
+			})
+			return
+		}
+		sendReport(ctx, format, job.Config, job.result)
+		return
+	}
+
+	writeJSON(ctx, http.StatusOK, job)
+}
+
+// API endpoint to cancel a running job, or delete a finished one
+func DeleteJob(ctx *gin.Context) {
+	job, ok := jobs.remove(ctx.Param("id"))
+	if !ok {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"message": "job not found",
+		})
+		return
+	}
+	if job.Status == JobRunning {
+		ctx.JSON(http.StatusAccepted, gin.H{
+			"message": "job cancelling",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "job deleted",
+	})
+}
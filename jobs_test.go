@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TheHackerDev/race-the-web/race"
+	"github.com/gin-gonic/gin"
+)
+
+// Function newTestAPI returns a server for the API endpoints.
+func newTestAPI() *httptest.Server {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	addRoutes(router)
+	return httptest.NewServer(router)
+}
+
+// Function apiRequest sends a request to the API, and returns the status code. The JSON response is decoded into v,
+// if given.
+func apiRequest(t *testing.T, method, url, body string, v interface{}) int {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("%s %s: response is not JSON: %v", method, url, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// Function startTestJob starts a job through the API, racing count requests to the target, and returns its ID.
+func startTestJob(t *testing.T, api, target string, count int) string {
+	var started struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	}
+	body := fmt.Sprintf(`{"count": %d, "requests": [{"method": "GET", "url": %q}]}`, count, target)
+	if code := apiRequest(t, "POST", api+"/jobs", body, &started); code != http.StatusAccepted || started.Status != JobRunning {
+		t.Fatalf("job started with status code %d, and status %q", code, started.Status)
+	}
+	return started.ID
+}
+
+// Function waitForJob waits until the job has finished, and returns it.
+func waitForJob(t *testing.T, id string) Job {
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		job, ok := jobs.get(id)
+		if !ok {
+			t.Fatalf("job %s not found", id)
+		}
+		if job.Status != JobRunning {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return Job{}
+}
+
+func TestDeleteJob(t *testing.T) {
+	release := make(chan struct{})
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-release
+	}))
+	defer target.Close()
+	defer close(release)
+	api := newTestAPI()
+	defer api.Close()
+
+	// A running job is cancelled, and kept with the responses received until then
+	id := startTestJob(t, api.URL, target.URL, 2)
+	var message struct {
+		Message string `json:"message"`
+	}
+	if code := apiRequest(t, "DELETE", api.URL+"/jobs/"+id, "", &message); code != http.StatusAccepted || message.Message != "job cancelling" {
+		t.Errorf("running job deleted with status code %d, and message %q", code, message.Message)
+	}
+	if job := waitForJob(t, id); job.Status != JobCancelled || job.Result == nil || !job.Result.Interrupted {
+		t.Errorf("cancelled job has status %q, and result %+v", job.Status, job.Result)
+	}
+	var job Job
+	if code := apiRequest(t, "GET", api.URL+"/jobs/"+id, "", &job); code != http.StatusOK || job.Status != JobCancelled {
+		t.Errorf("cancelled job fetched with status code %d, and status %q", code, job.Status)
+	}
+
+	// A finished job is deleted
+	if code := apiRequest(t, "DELETE", api.URL+"/jobs/"+id, "", &message); code != http.StatusOK || message.Message != "job deleted" {
+		t.Errorf("finished job deleted with status code %d, and message %q", code, message.Message)
+	}
+	if code := apiRequest(t, "GET", api.URL+"/jobs/"+id, "", nil); code != http.StatusNotFound {
+		t.Errorf("deleted job fetched with status code %d", code)
+	}
+	if code := apiRequest(t, "DELETE", api.URL+"/jobs/"+id, "", nil); code != http.StatusNotFound {
+		t.Errorf("deleted job deleted again with status code %d", code)
+	}
+}
+
+func TestEvictJobs(t *testing.T) {
+	now := time.Now()
+	at := func(when time.Time) *time.Time {
+		return &when
+	}
+	store := &jobStore{jobs: map[string]*Job{
+		"running": {ID: "running", Status: JobRunning, Created: now.Add(-2 * jobTTL)},
+		"expired": {ID: "expired", Status: JobCompleted, Finished: at(now.Add(-jobTTL - time.Second))},
+	}}
+	// Finished jobs beyond the limit, which finished a second apart
+	for i := 0; i < maxFinishedJobs+2; i++ {
+		id := fmt.Sprintf("finished-%d", i)
+		store.jobs[id] = &Job{ID: id, Status: JobCompleted, Finished: at(now.Add(-time.Duration(i) * time.Second))}
+	}
+
+	store.evict(now)
+	if len(store.jobs) != maxFinishedJobs+1 {
+		t.Errorf("%d jobs kept, want %d", len(store.jobs), maxFinishedJobs+1)
+	}
+	for id, kept := range map[string]bool{
+		"running":    true,
+		"expired":    false,
+		"finished-0": true,
+		fmt.Sprintf("finished-%d", maxFinishedJobs-1): true,
+		fmt.Sprintf("finished-%d", maxFinishedJobs):   false,
+		fmt.Sprintf("finished-%d", maxFinishedJobs+1): false,
+	} {
+		if _, ok := store.jobs[id]; ok != kept {
+			t.Errorf("job %s kept: %v, want %v", id, ok, kept)
+		}
+	}
+}
+
+func TestJobSnapshots(t *testing.T) {
+	finished := time.Now()
+	store := &jobStore{jobs: map[string]*Job{
+		"done": {
+			ID:       "done",
+			Status:   JobCompleted,
+			Finished: &finished,
+			Result:   newJobResult(&race.Result{}),
+			events:   []race.Event{{Type: race.EventSent}, {Type: race.EventReceived}},
+		},
+	}}
+
+	job, ok := store.get("done")
+	if !ok || job.Result == nil {
+		t.Fatalf("job fetched as %+v, want it with its result", job)
+	}
+	job.Status = JobFailed
+	job.Result = nil
+	if job, _ := store.get("done"); job.Status != JobCompleted || job.Result == nil {
+		t.Errorf("job changed through a snapshot: %+v", job)
+	}
+
+	// Listings leave the results out, without changing the jobs
+	list := store.list()
+	if len(list) != 1 || list[0].Result != nil {
+		t.Errorf("jobs listed as %+v, want the job without its result", list)
+	}
+	if job, _ := store.get("done"); job.Result == nil {
+		t.Errorf("result of the job removed by listing it")
+	}
+
+	events, status, _, ok := store.events("done", 1)
+	if !ok || status != JobCompleted || len(events) != 1 || events[0].Type != race.EventReceived {
+		t.Fatalf("events from 1 are %+v, with status %q", events, status)
+	}
+	events[0].Type = race.EventError
+	if events, _, _, _ := store.events("done", 0); events[1].Type != race.EventReceived {
+		t.Errorf("events changed through a snapshot: %+v", events)
+	}
+	if _, _, _, ok := store.events("missing", 0); ok {
+		t.Errorf("events found for a job that does not exist")
+	}
+}
+
+// sseEvent is an event read from a server-sent event stream.
+type sseEvent struct {
+	id, event, data string
+}
+
+// Function readEvents reads server-sent events until the stream ends, passing each one to seen as it is read, if set.
+func readEvents(t *testing.T, stream io.Reader, seen func(sseEvent)) []sseEvent {
+	var events []sseEvent
+	var event sseEvent
+	scanner := bufio.NewScanner(stream)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			events = append(events, event)
+			if seen != nil {
+				seen(event)
+			}
+			event = sseEvent{}
+			continue
+		}
+		split := strings.SplitN(line, ":", 2)
+		if len(split) != 2 {
+			t.Fatalf("invalid line in the event stream: %q", line)
+		}
+		value := strings.TrimPrefix(split[1], " ")
+		switch split[0] {
+		case "id":
+			event.id = value
+		case "event":
+			event.event = value
+		case "data":
+			event.data = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+// Function streamEvents reads the event stream of a job, resuming after lastID if it is not empty.
+func streamEvents(t *testing.T, api, id, lastID string, seen func(sseEvent)) []sseEvent {
+	req, err := http.NewRequest("GET", api+"/jobs/"+id+"/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		t.Fatalf("events streamed with status code %d, and content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	return readEvents(t, resp.Body, seen)
+}
+
+// Function checkEvents checks that the events of a job are numbered in order from first, and end with a "done" event
+// for the job with the status given. Returns the types of the events before the "done" event.
+func checkEvents(t *testing.T, name string, events []sseEvent, first int, id, status string) []string {
+	if len(events) == 0 {
+		t.Errorf("%s: no events streamed", name)
+		return nil
+	}
+	var types []string
+	for i, event := range events[:len(events)-1] {
+		types = append(types, event.event)
+		if event.id != strconv.Itoa(first+i) {
+			t.Errorf("%s: event %d has ID %q, want %d", name, i, event.id, first+i)
+		}
+		var data race.Event
+		if err := json.Unmarshal([]byte(event.data), &data); err != nil || data.Type != event.event {
+			t.Errorf("%s: %s event has data %q", name, event.event, event.data)
+		}
+	}
+
+	done := events[len(events)-1]
+	var data struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal([]byte(done.data), &data); err != nil || done.event != "done" || data.ID != id || data.Status != status {
+		t.Errorf("%s: last event is %s with data %q, want done for job %s with status %s", name, done.event, done.data, id, status)
+	}
+	return types
+}
+
+func TestStreamJobEvents(t *testing.T) {
+	release := make(chan struct{})
+	var releaseOnce sync.Once
+	releaseAll := func() {
+		releaseOnce.Do(func() { close(release) })
+	}
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-release
+		io.WriteString(w, "ok")
+	}))
+	defer target.Close()
+	defer releaseAll()
+	api := newTestAPI()
+	defer api.Close()
+
+	// Events are streamed as the job runs, which is held up until the first event is read
+	id := startTestJob(t, api.URL, target.URL, 2)
+	live := streamEvents(t, api.URL, id, "", func(sseEvent) { releaseAll() })
+	types := checkEvents(t, "live", live, 0, id, JobCompleted)
+	count := make(map[string]int)
+	for _, typ := range types {
+		count[typ]++
+	}
+	if count[race.EventSent] != 2 || count[race.EventReceived] != 2 || count[race.EventUnique] != 1 {
+		t.Errorf("live: events %q, want 2 sent, 2 received and 1 unique", types)
+	}
+
+	// Events are replayed once the job has finished, from the start or after the last event received
+	tests := []struct {
+		name   string
+		lastID string
+		first  int
+	}{
+		{"replayed", "", 0},
+		{"resumed", "1", 2},
+		{"resumed after the last event", strconv.Itoa(len(live) - 2), len(live) - 1},
+		{"invalid last event ID", "x", 0},
+	}
+	for _, test := range tests {
+		events := streamEvents(t, api.URL, id, test.lastID, nil)
+		replayed := checkEvents(t, test.name, events, test.first, id, JobCompleted)
+		if strings.Join(replayed, " ") != strings.Join(types[test.first:], " ") {
+			t.Errorf("%s: events %q, want %q", test.name, replayed, types[test.first:])
+		}
+	}
+
+	if code := apiRequest(t, "GET", api.URL+"/jobs/missing/events", "", nil); code != http.StatusNotFound {
+		t.Errorf("events of a job that does not exist streamed with status code %d", code)
+	}
+}
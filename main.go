@@ -1,24 +1,100 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"strings"
+
+	"github.com/TheHackerDev/race-the-web/report"
+
+	// Used to output in colour to the console
+	"github.com/fatih/color"
+)
+
+// Usage message
+var usage string
+
+// Output formats for the command line
+const (
+	outputText   = "text"   // Every unique response in full
+	outputDiff   = "diff"   // The baseline response in full, and a diff of every other unique response against it
+	outputJSON   = "json"   // The result as a single JSON document, in the same structure as the results of API jobs
+	outputNDJSON = "ndjson" // The result as one JSON record per line, for each unique response, error and the summary
 )
 
+// Exit codes of the command line, so that CI pipelines can gate on the outcome of a race test
+const (
+	exitOK              = 0
+	exitError           = 1 // The race test ran, but its report could not be written, or its responses could not be saved
+	exitConfigError     = 2 // The configuration is invalid, and the race test did not run
+	exitAssertionFailed = 3 // An assertion failed
+	exitErrorsExceeded  = 4 // More requests failed than the assertions allow, or the race was interrupted, so the result cannot be trusted
+	exitVulnerable      = 5 // The verdict is VULNERABLE
+)
+
+// Command-line flags
+var outputFormat string
+var reports reportFlags
+var saveDir string
+
+// reportFlags collects the reports requested on the command line, each as "format:path"
+type reportFlags []string
+
+// Function String returns the reports requested, as required by flag.Value
+func (f *reportFlags) String() string {
+	return strings.Join(*f, ", ")
+}
+
+// Function Set adds a report, as required by flag.Value
+func (f *reportFlags) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// Colour outputs
+var outError = color.New(color.FgRed).PrintfFunc()
+var outVulnerable = color.New(color.FgRed, color.Bold).PrintfFunc()
+var outNotVulnerable = color.New(color.FgGreen, color.Bold).PrintfFunc()
+var outInconclusive = color.New(color.FgYellow, color.Bold).PrintfFunc()
+
+// Function init initializes the program defaults
+func init() {
+	usage = fmt.Sprintf("Usage: %s [--output text|diff|json|ndjson] [--report format:path]... [--save dir] config.toml\n       %s [--output text|diff|json|ndjson] [--report format:path]... [--save dir] from-curl [--count n] [--run] [curl command]", os.Args[0], os.Args[0])
+
+	flag.StringVar(&outputFormat, "output", outputText, "Output format: \"text\", \"diff\", \"json\" or \"ndjson\"")
+	flag.Var(&reports, "report", fmt.Sprintf("Write a report to a file, as format:path. May be repeated. Formats: %s", strings.Join(report.Formats, ", ")))
+	flag.StringVar(&saveDir, "save", "", "Save every request and response to this directory, with an index of their unique responses")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+}
+
 // Main entry function for the program
 func main() {
 	// Change output location of logs
 	log.SetOutput(os.Stdout)
 
+	flag.Parse()
+
 	// Run from command-line if arguments are provided- this means that a configuration file has been provided
-	if len(os.Args) >= 2 {
-		// Start cmd
-		if err := StartCMD(); err != nil {
-			fmt.Println(usage)
+	if flag.NArg() >= 1 {
+		// Start cmd, or convert a curl command into a request
+		var code int
+		var err error
+		if flag.Arg(0) == "from-curl" {
+			code, err = StartFromCurl(flag.Args()[1:])
+		} else {
+			code, err = StartCMD()
+		}
+		if err != nil {
+			// Kept with the error, which is not on standard output when the output is JSON
+			fmt.Fprintln(color.Output, usage)
 			outError("[ERROR] %s\n", err)
-			os.Exit(1)
 		}
+		os.Exit(code)
 
 	} else {
 		// Start API
@@ -0,0 +1,91 @@
+package race
+
+import "fmt"
+
+// Assertions are conditions that the result of a race must meet, so that CI pipelines can fail when a race condition is found.
+// Conditions that are not set are not checked.
+type Assertions struct {
+	MaxUnique    *int `json:"max_unique,omitempty"`    // Most unique responses allowed
+	MaxSuccesses *int `json:"max_successes,omitempty"` // Most successful responses allowed, across every request with a success matcher
+	No5xx        bool `json:"no_5xx,omitempty"`        // Fail on any response with a 5xx status code
+	MaxErrors    *int `json:"max_errors,omitempty"`    // Most requests allowed to fail without a response. Beyond it, the result cannot be trusted.
+}
+
+// Names of the assertions, as set in the configuration
+const (
+	AssertMaxUnique    = "max_unique"
+	AssertMaxSuccesses = "max_successes"
+	AssertNo5xx        = "no_5xx"
+)
+
+// AssertionFailure describes an assertion that the result of a race does not meet.
+type AssertionFailure struct {
+	Assertion string // Name of the assertion, such as max_unique
+	Message   string
+}
+
+// Function String returns the message describing the failure.
+func (f AssertionFailure) String() string {
+	return f.Message
+}
+
+// Function validate checks that the assertions can be evaluated for the configuration.
+func (a *Assertions) validate(config Configuration) error {
+	for _, limit := range []*int{a.MaxUnique, a.MaxSuccesses, a.MaxErrors} {
+		if limit != nil && *limit < 0 {
+			return fmt.Errorf("Assertion limits cannot be negative.")
+		}
+	}
+	if a.MaxSuccesses != nil {
+		for _, t := range config.Requests {
+			if t.Success != nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("Asserting the most successes allowed requires a success matcher on at least one request.")
+	}
+	return nil
+}
+
+// Function check evaluates the assertions against the result, recording the ones that failed,
+// and whether too many requests failed for the result to be trusted.
+func (a *Assertions) check(result *Result) {
+	if a.MaxErrors != nil && result.Failed > *a.MaxErrors {
+		result.ErrorsExceeded = true
+	}
+
+	if a.MaxUnique != nil && len(result.Responses) > *a.MaxUnique {
+		result.AssertionFailures = append(result.AssertionFailures, AssertionFailure{
+			Assertion: AssertMaxUnique,
+			Message:   fmt.Sprintf("%d unique responses received, expected at most %d", len(result.Responses), *a.MaxUnique),
+		})
+	}
+
+	if a.MaxSuccesses != nil {
+		successes := 0
+		for _, resp := range result.Responses {
+			successes += resp.Successes
+		}
+		if successes > *a.MaxSuccesses {
+			result.AssertionFailures = append(result.AssertionFailures, AssertionFailure{
+				Assertion: AssertMaxSuccesses,
+				Message:   fmt.Sprintf("%d successful responses received, expected at most %d", successes, *a.MaxSuccesses),
+			})
+		}
+	}
+
+	if a.No5xx {
+		count := 0
+		for _, resp := range result.Responses {
+			if resp.Response.StatusCode >= 500 && resp.Response.StatusCode < 600 {
+				count += resp.Count
+			}
+		}
+		if count > 0 {
+			result.AssertionFailures = append(result.AssertionFailures, AssertionFailure{
+				Assertion: AssertNo5xx,
+				Message:   fmt.Sprintf("%d responses received with a 5xx status code, expected none", count),
+			})
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package race
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTimedOutRequestsFail(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/slow" {
+			<-release
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	zero := 0
+	config := Configuration{
+		Count:   3,
+		Timeout: 1,
+		Assert:  &Assertions{MaxErrors: &zero},
+		Requests: []Request{
+			{Method: "GET", URL: server.URL + "/fast"},
+			{Method: "GET", URL: server.URL + "/slow"},
+		},
+	}
+	result, err := NewRunner(config).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Interrupted {
+		t.Error("the race was not interrupted")
+	}
+	if result.Failed != 3 {
+		t.Errorf("%d requests failed, want the 3 abandoned", result.Failed)
+	}
+	if !result.ErrorsExceeded {
+		t.Error("abandoned requests did not exceed max_errors")
+	}
+}
@@ -0,0 +1,106 @@
+package race
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// burpItems holds the parts of a Burp Suite "Save items" file that requests are imported from.
+type burpItems struct {
+	Items []burpItem `xml:"item"`
+}
+
+type burpItem struct {
+	URL      string `xml:"url"`
+	Host     string `xml:"host"`
+	Port     string `xml:"port"`
+	Protocol string `xml:"protocol"`
+	Method   string `xml:"method"`
+	Request  struct {
+		Base64 bool   `xml:"base64,attr"`
+		Raw    string `xml:",chardata"`
+	} `xml:"request"`
+}
+
+// Function importBurp builds a request from every item of the target's Burp Suite XML file that its filters pick,
+// read from dir if the path is relative. Each item holds a raw request, which is parsed as raw_request files are,
+// and sent to the host and with the scheme recorded with it, unless the target sets them.
+// Fields that are already set in the target are kept. Returns an error if no item is picked.
+func importBurp(t Request, dir string) ([]Request, error) {
+	raw, err := ioutil.ReadFile(resolvePath(dir, t.Burp))
+	if err != nil {
+		return nil, fmt.Errorf("could not read Burp file: %v", err)
+	}
+	var burp burpItems
+	if err := xml.Unmarshal(raw, &burp); err != nil {
+		return nil, fmt.Errorf("could not parse Burp file %s: %v", t.Burp, err)
+	}
+	var urlPattern *regexp.Regexp
+	if t.BurpURL != "" {
+		if urlPattern, err = regexp.Compile(t.BurpURL); err != nil {
+			return nil, fmt.Errorf("invalid Burp URL pattern %q: %v", t.BurpURL, err)
+		}
+	}
+	items := burp.Items
+	if t.BurpIndex != nil && (*t.BurpIndex < 0 || *t.BurpIndex >= len(items)) {
+		return nil, fmt.Errorf("Burp file %s has no item %d, as it has %d items", t.Burp, *t.BurpIndex, len(items))
+	}
+
+	var requests []Request
+	for i, item := range items {
+		switch {
+		case t.BurpIndex != nil && i != *t.BurpIndex:
+			continue
+		case t.BurpMethod != "" && !strings.EqualFold(strings.TrimSpace(item.Method), t.BurpMethod):
+			continue
+		case urlPattern != nil && !urlPattern.MatchString(strings.TrimSpace(item.URL)):
+			continue
+		}
+		parsed, err := parseBurpItem(item, t.Scheme, t.Host)
+		if err != nil {
+			return nil, fmt.Errorf("Burp item %d: %v", i, err)
+		}
+
+		request := t.withImported(parsed)
+		request.Burp, request.BurpURL, request.BurpMethod, request.BurpIndex = "", "", "", nil
+		request.Scheme, request.Host = "", ""
+		requests = append(requests, request)
+	}
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("no item of Burp file %s matches its filters", t.Burp)
+	}
+	return requests, nil
+}
+
+// Function parseBurpItem decodes the raw request of a Burp item, and parses it with the scheme and host recorded
+// with it. The scheme and host given override them.
+func parseBurpItem(item burpItem, scheme, host string) (Request, error) {
+	raw := []byte(item.Request.Raw)
+	if item.Request.Base64 {
+		var err error
+		if raw, err = base64.StdEncoding.DecodeString(strings.TrimSpace(item.Request.Raw)); err != nil {
+			return Request{}, fmt.Errorf("could not decode request: %v", err)
+		}
+	}
+	if len(strings.TrimSpace(string(raw))) == 0 {
+		return Request{}, fmt.Errorf("no request was saved")
+	}
+
+	if scheme == "" {
+		scheme = strings.TrimSpace(item.Protocol)
+	}
+	if host == "" {
+		host = strings.TrimSpace(item.Host)
+		// The port is only added if it is not the default for the scheme
+		port := strings.TrimSpace(item.Port)
+		if host != "" && port != "" && !(scheme == "https" && port == "443") && !(scheme == "http" && port == "80") {
+			host = net.JoinHostPort(host, port)
+		}
+	}
+	return parseRawRequest(raw, scheme, host)
+}
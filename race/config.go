@@ -0,0 +1,175 @@
+package race
+
+import (
+	"net/http"
+	"time"
+)
+
+// Configuration holds all the configuration data passed in from the config.TOML file.
+// Defaults:
+// Count: 100
+// Verbose: false
+// Proxy: *none*
+// Sync: *none* (requests are sent as soon as they are ready)
+// Timeout: *none* (in seconds, for the whole race)
+// Similarity: *none* (responses are only grouped if they are the same)
+// Baseline: *none* (the most common response)
+type Configuration struct {
+	Count      int         `json:"count"`
+	Verbose    bool        `json:"verbose"`
+	Proxy      string      `json:"proxy"`
+	Sync       string      `json:"sync"`
+	Timeout    int         `json:"timeout"`
+	Similarity float64     `json:"similarity"`       // Group responses whose bodies are at least this similar, from 0 to 1
+	Baseline   int         `json:"baseline"`         // Number of the unique response, starting from 1, that the others are diffed against
+	Assert     *Assertions `json:"assert,omitempty"` // Conditions the result must meet
+	Requests   []Request   `json:"requests" binding:"required"`
+}
+
+// SyncLastByte is the synchronization mode that writes every request except its final byte,
+// and then releases all of the final bytes together.
+const SyncLastByte = "last-byte"
+
+// Request is a struct to hold information about an individual request being made as a part of the race condition test.
+type Request struct {
+	Method      string          `json:"method" binding:"required"`
+	URL         string          `json:"url" binding:"required"`
+	Body        string          `json:"body"`
+	Cookies     []string        `json:"cookies"`
+	Headers     []string        `json:"headers"`
+	Redirects   bool            `json:"redirects"`
+	HTTP2       bool            `json:"http2"`
+	Timeout     int             `json:"timeout"`                // In seconds, for each request sent to this target. Defaults to DefaultRequestTimeout.
+	Normalize   *Normalization  `json:"normalize,omitempty"`    // Rules applied to the responses before they are compared
+	Compare     string          `json:"compare,omitempty"`      // How response bodies are compared: CompareBody (default) or CompareJSON
+	JSONInclude []string        `json:"json_include,omitempty"` // With CompareJSON, compare only the values at these JSON pointers
+	JSONExclude []string        `json:"json_exclude,omitempty"` // With CompareJSON, leave out the values at these JSON pointers
+	Success     *SuccessMatcher `json:"success,omitempty"`      // Decides which responses mean that the request succeeded
+	CookieJar   http.CookieJar  `json:"-"`                      // Ignore this field, as it is usually nil when outputting via the API
+
+	// Files that the {{word}} and {{csv:column}} template variables take their values from. Each copy of the request
+	// takes the line of the wordlist, and the row of the CSV file after its header row, with its own number.
+	Wordlist string `json:"wordlist,omitempty"`
+	CSV      string `json:"csv,omitempty"`
+
+	// Raw HTTP request file that the method, URL, headers, cookies and body are taken from, with ImportRequests.
+	// Raw requests hold no scheme, and only the host from their Host header, so both can be overridden.
+	RawRequest string `json:"raw_request,omitempty"`
+	Scheme     string `json:"scheme,omitempty"` // Scheme of a raw request: "https" (default) or "http"
+	Host       string `json:"host,omitempty"`   // Host of a raw request, with its port if not the default, instead of its Host header
+
+	// HAR file that the method, URL, headers, cookies and body are taken from, with ImportRequests. The entries are
+	// picked by every filter that is set, and each entry picked becomes a request of its own.
+	HAR       string `json:"har,omitempty"`
+	HARURL    string `json:"har_url,omitempty"`    // Regular expression matching the URL of the entries
+	HARMethod string `json:"har_method,omitempty"` // Method of the entries
+	HARIndex  *int   `json:"har_index,omitempty"`  // Index of the entry in the HAR file, starting from 0
+
+	// Burp Suite XML file, saved with "Save items", that the method, URL, headers, cookies and body are taken from,
+	// with ImportRequests. The items are picked by every filter that is set, and each item picked becomes a request
+	// of its own. Scheme and Host override those recorded with the items.
+	Burp       string `json:"burp,omitempty"`
+	BurpURL    string `json:"burp_url,omitempty"`    // Regular expression matching the URL of the items
+	BurpMethod string `json:"burp_method,omitempty"` // Method of the items
+	BurpIndex  *int   `json:"burp_index,omitempty"`  // Index of the item in the Burp file, starting from 0
+}
+
+// Copy returns a deep copy of the configuration, which can be changed without affecting the original.
+func (c Configuration) Copy() Configuration {
+	if c.Assert != nil {
+		assert := *c.Assert
+		assert.MaxUnique = copyInt(assert.MaxUnique)
+		assert.MaxSuccesses = copyInt(assert.MaxSuccesses)
+		assert.MaxErrors = copyInt(assert.MaxErrors)
+		c.Assert = &assert
+	}
+	if c.Requests != nil {
+		requests := make([]Request, len(c.Requests))
+		for i, t := range c.Requests {
+			requests[i] = t.copy()
+		}
+		c.Requests = requests
+	}
+	return c
+}
+
+// Function copy returns a deep copy of the request. The cookie jar is shared, as it is not part of the configuration.
+func (t Request) copy() Request {
+	t.Cookies = copyStrings(t.Cookies)
+	t.Headers = copyStrings(t.Headers)
+	t.JSONInclude = copyStrings(t.JSONInclude)
+	t.JSONExclude = copyStrings(t.JSONExclude)
+	if t.Normalize != nil {
+		n := *t.Normalize
+		if n.Replace != nil {
+			n.Replace = append([]Replacement{}, n.Replace...)
+		}
+		n.IgnoreJSON = copyStrings(n.IgnoreJSON)
+		n.IgnoreHeaders = copyStrings(n.IgnoreHeaders)
+		t.Normalize = &n
+	}
+	if t.Success != nil {
+		s := *t.Success
+		if s.Status != nil {
+			s.Status = append([]int{}, s.Status...)
+		}
+		s.Max = copyInt(s.Max)
+		t.Success = &s
+	}
+	t.HARIndex = copyInt(t.HARIndex)
+	t.BurpIndex = copyInt(t.BurpIndex)
+	return t
+}
+
+// Function copyStrings returns a copy of the slice, keeping nil slices nil.
+func copyStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string{}, s...)
+}
+
+// Function copyInt returns a pointer to a copy of the value, or nil.
+func copyInt(i *int) *int {
+	if i == nil {
+		return nil
+	}
+	v := *i
+	return &v
+}
+
+// Comparison modes for response bodies
+const (
+	CompareBody = "body" // Compare the bodies byte for byte
+	CompareJSON = "json" // Compare JSON bodies by structure, ignoring key order and whitespace
+)
+
+// DefaultRequestTimeout is the time allowed for each request, unless its target sets a timeout.
+const DefaultRequestTimeout = 120 * time.Second
+
+// Function requestTimeout returns the time allowed for each request sent to the target,
+// from the moment it is released until its response has been read.
+func (t Request) requestTimeout() time.Duration {
+	if t.Timeout > 0 {
+		return time.Duration(t.Timeout) * time.Second
+	}
+	return DefaultRequestTimeout
+}
+
+// REF: Access parts of the Configuration object.
+// fmt.Printf("All: %v\n", config)
+// fmt.Printf("Count: %v\n", config.Count)
+// fmt.Printf("Verbose: %v\n", config.Verbose)
+// fmt.Println("Targets:")
+// for _, target := range config.Target {
+// 	fmt.Printf("\n\tMethod: %s\n", target.Method)
+// 	fmt.Printf("\tURL: %s\n", target.Url)
+// 	fmt.Printf("\tBody: %s\n", target.Body)
+// 	fmt.Printf("\tRedirects: %v\n", target.Redirects)
+// 	for _, cookie := range target.Cookies {
+// 		fmt.Printf("\tCookie: %s\n", cookie)
+// 	}
+// 	// Add the cookie jar after TOML is unmarshaled
+// 	target.CookieJar, _ = cookiejar.New(nil)
+// 	fmt.Printf("\tCookieJar: %v\n", target.CookieJar)
+// }
@@ -0,0 +1,313 @@
+package race
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change in a unified diff.
+const diffContext = 3
+
+// maxDiffEdits limits the time spent on diffing very different texts. Beyond it, the whole text is shown as replaced.
+const maxDiffEdits = 1000
+
+// diffNoNewline follows the last line of a text that does not end with a newline, as in diff and patch.
+const diffNoNewline = "\\ No newline at end of file"
+
+// diffOp is a single line of an edit script: an unchanged line (' '), a removed line ('-') or an added line ('+').
+type diffOp struct {
+	kind byte
+	a, b int // Line numbers in the old and new texts, starting at 0
+}
+
+// Function diffResponses picks the baseline response, either the one chosen in the configuration or the most common,
+// and diffs every other unique response against it. Returns an error if the chosen baseline does not exist,
+// in which case the most common response is used instead.
+func (r *Runner) diffResponses(result *Result) (err error) {
+	if len(result.Responses) == 0 {
+		return nil
+	}
+
+	baseline := -1
+	if r.Config.Baseline > 0 {
+		if r.Config.Baseline <= len(result.Responses) {
+			baseline = r.Config.Baseline - 1
+		} else {
+			err = fmt.Errorf("Baseline response #%d does not exist, as there are %d unique responses. Using the most common response instead.", r.Config.Baseline, len(result.Responses))
+		}
+	}
+	if baseline < 0 {
+		baseline = 0
+		for i, resp := range result.Responses {
+			if resp.Count > result.Responses[baseline].Count {
+				baseline = i
+			}
+		}
+	}
+	result.Baseline = baseline
+
+	baseName := fmt.Sprintf("response #%d", baseline+1)
+	baseText := responseText(result.Responses[baseline].Response)
+	for i := range result.Responses {
+		if i == baseline {
+			continue
+		}
+		result.Responses[i].Diff = unifiedDiff(baseName, fmt.Sprintf("response #%d", i+1), baseText, responseText(result.Responses[i].Response))
+	}
+	return err
+}
+
+// Function responseText renders a response as the text compared in diffs: its status code, headers in a fixed order, and body.
+func responseText(data UniqueResponseData) string {
+	return fmt.Sprintf("Status: %d\n%s\n%s", data.StatusCode, headerKey(data.Headers, nil), data.Body)
+}
+
+// Function unifiedDiff returns the differences between two texts as a unified diff, labelling them with the names given.
+// Returns an empty string if the texts are the same.
+func unifiedDiff(oldName, newName, oldText, newText string) string {
+	if oldText == newText {
+		return ""
+	}
+	a, b := splitLines(oldText), splitLines(newText)
+	ops := diffLines(a, b)
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+	for start := 0; start < len(ops); {
+		// Find the next change
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+
+		// Extend the hunk until the changes are separated by more unchanged lines than both contexts together
+		end := start
+		for i := start; i < len(ops); i++ {
+			if ops[i].kind != ' ' {
+				end = i + 1
+			} else if i-end >= 2*diffContext {
+				break
+			}
+		}
+		first := start - diffContext
+		if first < 0 {
+			first = 0
+		}
+		last := end + diffContext
+		if last > len(ops) {
+			last = len(ops)
+		}
+
+		writeHunk(&out, a, b, ops[first:last])
+		start = last
+	}
+	return out.String()
+}
+
+// Function writeHunk writes a single hunk of a unified diff.
+func writeHunk(out *bytes.Buffer, a, b []string, ops []diffOp) {
+	var aStart, aLen, bStart, bLen int
+	aStart, bStart = -1, -1
+	for _, op := range ops {
+		if op.kind != '+' {
+			if aStart < 0 {
+				aStart = op.a
+			}
+			aLen++
+		}
+		if op.kind != '-' {
+			if bStart < 0 {
+				bStart = op.b
+			}
+			bLen++
+		}
+	}
+	// Empty ranges are numbered after the line they follow
+	if aStart < 0 {
+		aStart = ops[0].a - 1
+	}
+	if bStart < 0 {
+		bStart = ops[0].b - 1
+	}
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(aStart, aLen), hunkRange(bStart, bLen))
+
+	for _, op := range ops {
+		var line string
+		if op.kind == '+' {
+			line = b[op.b]
+		} else {
+			line = a[op.a]
+		}
+		fmt.Fprintf(out, "%c%s\n", op.kind, strings.TrimSuffix(line, "\n"))
+		if !strings.HasSuffix(line, "\n") {
+			fmt.Fprintln(out, diffNoNewline)
+		}
+	}
+}
+
+// Function hunkRange formats the range of lines of a hunk, numbered from 1.
+func hunkRange(start, length int) string {
+	if length == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
+
+// Function splitLines splits a text into lines, each with its newline, so that a last line without one differs
+// from the same line with one.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Function diffLines returns the shortest edit script turning the lines of a into the lines of b, using the linear
+// space variant of Myers' algorithm. The line numbers of removed lines refer to a, and those of added lines to b;
+// unchanged lines refer to both.
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	if n+m == 0 {
+		return nil
+	}
+	d := &differ{
+		a:   a,
+		b:   b,
+		ops: make([]diffOp, 0, n+m),
+		vf:  make([]int, n+m+4),
+		vb:  make([]int, n+m+4),
+	}
+	if d.compare(0, n, 0, m, maxDiffEdits) {
+		return d.ops
+	}
+
+	// Too many changes to be worth finding the shortest script
+	ops := make([]diffOp, 0, n+m)
+	for i := range a {
+		ops = append(ops, diffOp{kind: '-', a: i, b: 0})
+	}
+	for j := range b {
+		ops = append(ops, diffOp{kind: '+', a: n, b: j})
+	}
+	return ops
+}
+
+// differ holds the state of diffLines. The furthest points reached on each diagonal are kept in vf and vb,
+// which are shared by every part of the texts compared, as they are compared one after the other.
+type differ struct {
+	a, b   []string
+	ops    []diffOp
+	vf, vb []int
+}
+
+// Function compare appends the edit script turning a[aLo:aHi] into b[bLo:bHi], by finding the middle of the
+// shortest script, and comparing the parts before and after it in turn. Returns false, having appended an
+// incomplete script, if the script needs more than limit edits, unless limit is 0.
+func (d *differ) compare(aLo, aHi, bLo, bHi, limit int) bool {
+	// Unchanged lines at the start and end are left out of the search
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		d.ops = append(d.ops, diffOp{kind: ' ', a: aLo, b: bLo})
+		aLo++
+		bLo++
+	}
+	aEnd := aHi
+	for aHi > aLo && bHi > bLo && d.a[aHi-1] == d.b[bHi-1] {
+		aHi--
+		bHi--
+	}
+
+	switch {
+	case aLo == aHi:
+		for j := bLo; j < bHi; j++ {
+			d.ops = append(d.ops, diffOp{kind: '+', a: aLo, b: j})
+		}
+	case bLo == bHi:
+		for i := aLo; i < aHi; i++ {
+			d.ops = append(d.ops, diffOp{kind: '-', a: i, b: bLo})
+		}
+	default:
+		x, y, u, v, ok := d.middleSnake(aLo, aHi, bLo, bHi, limit)
+		if !ok {
+			return false
+		}
+		d.compare(aLo, x, bLo, y, 0)
+		for ; x < u; x, y = x+1, y+1 {
+			d.ops = append(d.ops, diffOp{kind: ' ', a: x, b: y})
+		}
+		d.compare(u, aHi, v, bHi, 0)
+	}
+
+	for ; aHi < aEnd; aHi, bHi = aHi+1, bHi+1 {
+		d.ops = append(d.ops, diffOp{kind: ' ', a: aHi, b: bHi})
+	}
+	return true
+}
+
+// Function middleSnake searches for the shortest edit script turning a[aLo:aHi] into b[bLo:bHi] from both ends at
+// once, until the searches overlap. Returns the run of unchanged lines from (x, y) to (u, v) in the middle of the
+// script, or false if the script needs more than limit edits, unless limit is 0.
+// Both parts must hold lines, and differ in their first and last lines.
+func (d *differ) middleSnake(aLo, aHi, bLo, bHi, limit int) (x, y, u, v int, ok bool) {
+	n, m := aHi-aLo, bHi-bLo
+	delta := n - m
+	odd := delta%2 != 0
+
+	// vf holds the furthest x reached from the start on each diagonal k = x - y, and vb the furthest reached
+	// from the end on each diagonal of the reversed texts, both offset by max
+	max := (n + m + 1) / 2
+	vf, vb := d.vf[:2*max+3], d.vb[:2*max+3]
+	vf[max+1], vb[max+1] = 0, 0
+	for e := 0; e <= max; e++ {
+		if limit > 0 && 2*e > limit {
+			return 0, 0, 0, 0, false
+		}
+
+		for k := -e; k <= e; k += 2 {
+			var x int
+			if k == -e || (k != e && vf[max+k-1] < vf[max+k+1]) {
+				x = vf[max+k+1] // Down, adding a line of b
+			} else {
+				x = vf[max+k-1] + 1 // Right, removing a line of a
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && d.a[aLo+x] == d.b[bLo+y] {
+				x++
+				y++
+			}
+			vf[max+k] = x
+			// The same diagonal of the reversed texts, as searched in the previous step
+			if kr := delta - k; odd && kr >= -(e-1) && kr <= e-1 && x+vb[max+kr] >= n {
+				return aLo + startX, bLo + startY, aLo + x, bLo + y, true
+			}
+		}
+
+		for k := -e; k <= e; k += 2 {
+			var x int
+			if k == -e || (k != e && vb[max+k-1] < vb[max+k+1]) {
+				x = vb[max+k+1]
+			} else {
+				x = vb[max+k-1] + 1
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && d.a[aHi-1-x] == d.b[bHi-1-y] {
+				x++
+				y++
+			}
+			vb[max+k] = x
+			if kf := delta - k; !odd && kf >= -e && kf <= e && x+vf[max+kf] >= n {
+				return aHi - x, bHi - y, aHi - startX, bHi - startY, true
+			}
+		}
+	}
+	// Not reached, as the searches always overlap
+	return 0, 0, 0, 0, false
+}
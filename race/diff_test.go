@@ -0,0 +1,96 @@
+package race
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		edits int
+	}{
+		{"", "", 0},
+		{"a b c", "a b c", 0},
+		{"", "a b", 2},
+		{"a b", "", 2},
+		{"a b c", "a c", 1},
+		{"a c", "a b c", 1},
+		{"a b c a b b a", "c b a b a c", 5},
+		{"x y z", "p q r", 6},
+		{"a b c d e f", "f e d c b a", 10},
+		{"a a a b", "b a a a", 2},
+		{"p a b c q", "r a b c s", 4},
+	}
+	for _, test := range tests {
+		a, b := strings.Fields(test.a), strings.Fields(test.b)
+		ops := diffLines(a, b)
+		if edits := checkDiff(t, a, b, ops); edits != test.edits {
+			t.Errorf("diffLines(%q, %q) made %d edits, want %d", test.a, test.b, edits, test.edits)
+		}
+	}
+
+	// Texts too different to search are shown as replaced
+	var a, b []string
+	for i := 0; i < maxDiffEdits; i++ {
+		a = append(a, "a"+strings.Repeat("x", i))
+		b = append(b, "b"+strings.Repeat("x", i))
+	}
+	if edits := checkDiff(t, a, b, diffLines(a, b)); edits != 2*maxDiffEdits {
+		t.Errorf("diffLines made %d edits for texts with no lines in common, want %d", edits, 2*maxDiffEdits)
+	}
+}
+
+// Function checkDiff checks that the edit script turns a into b, returning the number of edits.
+func checkDiff(t *testing.T, a, b []string, ops []diffOp) int {
+	var x, y, edits int
+	for _, op := range ops {
+		switch op.kind {
+		case ' ':
+			if op.a != x || op.b != y || a[x] != b[y] {
+				t.Fatalf("diffLines(%q, %q): unexpected unchanged line %+v", a, b, op)
+			}
+			x++
+			y++
+		case '-':
+			if op.a != x {
+				t.Fatalf("diffLines(%q, %q): unexpected removed line %+v", a, b, op)
+			}
+			x++
+			edits++
+		case '+':
+			if op.b != y {
+				t.Fatalf("diffLines(%q, %q): unexpected added line %+v", a, b, op)
+			}
+			y++
+			edits++
+		}
+	}
+	if x != len(a) || y != len(b) {
+		t.Fatalf("diffLines(%q, %q) stopped at line %d of a and %d of b", a, b, x, y)
+	}
+	return edits
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		old, new string
+		diff     string
+	}{
+		{"a\nb\n", "a\nb\n", ""},
+		{"a\nb\nc\n", "a\nB\nc\n", "--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n"},
+		{"x", "x\n", "--- old\n+++ new\n@@ -1 +1 @@\n-x\n\\ No newline at end of file\n+x\n"},
+		{"a\nb", "a\nc", "--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n"},
+		{"", "a\n", "--- old\n+++ new\n@@ -0,0 +1 @@\n+a\n"},
+		{
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			"0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n",
+			"--- old\n+++ new\n@@ -1,3 +1,4 @@\n+0\n 1\n 2\n 3\n@@ -9,4 +10,3 @@\n 9\n 10\n 11\n-12\n",
+		},
+	}
+	for _, test := range tests {
+		if diff := unifiedDiff("old", "new", test.old, test.new); diff != test.diff {
+			t.Errorf("unifiedDiff(%q, %q) = %q, want %q", test.old, test.new, diff, test.diff)
+		}
+	}
+}
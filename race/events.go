@@ -0,0 +1,34 @@
+package race
+
+import "time"
+
+// Event types reported while a race runs
+const (
+	EventSent     = "sent"     // A request was released to the target
+	EventReceived = "received" // A response was received
+	EventError    = "error"    // A request failed
+	EventUnique   = "unique"   // A new unique response was found while comparing the responses
+)
+
+// Event reports the progress of a race as it runs.
+type Event struct {
+	Type       string    `json:"type"`
+	Time       time.Time `json:"time"`
+	Method     string    `json:"method,omitempty"`
+	URL        string    `json:"url,omitempty"`
+	StatusCode int       `json:"status_code,omitempty"` // Status code of the response, for received and unique events
+	Unique     int       `json:"unique,omitempty"`      // Number of the unique response, starting from 1, for unique events
+	Error      string    `json:"error,omitempty"`
+}
+
+// Function emit passes an event for the target to the runner's event handler, if one is set.
+func (r *Runner) emit(typ string, t Request, event Event) {
+	if r.Events == nil {
+		return
+	}
+	event.Type = typ
+	event.Time = time.Now()
+	event.Method = t.Method
+	event.URL = t.URL
+	r.Events(event)
+}
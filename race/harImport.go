@@ -0,0 +1,139 @@
+package race
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// harImportLog holds the parts of a HAR file that requests are imported from.
+type harImportLog struct {
+	Log struct {
+		Entries []struct {
+			Request harImportRequest `json:"request"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+type harImportRequest struct {
+	Method  string `json:"method"`
+	URL     string `json:"url"`
+	Headers []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"headers"`
+	Cookies []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"cookies"`
+	PostData *struct {
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text"`
+		Params   []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"params"`
+	} `json:"postData"`
+}
+
+// Function importHAR builds a request from every entry of the target's HAR file that its filters pick, read from dir
+// if the path is relative. Fields that are already set in the target are kept, while the headers and cookies of each
+// entry are added before those of the target. Returns an error if no entry is picked.
+func importHAR(t Request, dir string) ([]Request, error) {
+	raw, err := ioutil.ReadFile(resolvePath(dir, t.HAR))
+	if err != nil {
+		return nil, fmt.Errorf("could not read HAR file: %v", err)
+	}
+	var har harImportLog
+	if err := json.Unmarshal(raw, &har); err != nil {
+		return nil, fmt.Errorf("could not parse HAR file %s: %v", t.HAR, err)
+	}
+	var urlPattern *regexp.Regexp
+	if t.HARURL != "" {
+		if urlPattern, err = regexp.Compile(t.HARURL); err != nil {
+			return nil, fmt.Errorf("invalid HAR URL pattern %q: %v", t.HARURL, err)
+		}
+	}
+	entries := har.Log.Entries
+	if t.HARIndex != nil && (*t.HARIndex < 0 || *t.HARIndex >= len(entries)) {
+		return nil, fmt.Errorf("HAR file %s has no entry %d, as it has %d entries", t.HAR, *t.HARIndex, len(entries))
+	}
+
+	var requests []Request
+	for i, entry := range entries {
+		harReq := entry.Request
+		switch {
+		case t.HARIndex != nil && i != *t.HARIndex:
+			continue
+		case t.HARMethod != "" && !strings.EqualFold(harReq.Method, t.HARMethod):
+			continue
+		case urlPattern != nil && !urlPattern.MatchString(harReq.URL):
+			continue
+		}
+		parsed, err := parseHARRequest(harReq)
+		if err != nil {
+			return nil, fmt.Errorf("HAR entry %d: %v", i, err)
+		}
+
+		request := t.withImported(parsed)
+		request.HAR, request.HARURL, request.HARMethod, request.HARIndex = "", "", "", nil
+		requests = append(requests, request)
+	}
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("no entry of HAR file %s matches its filters", t.HAR)
+	}
+	return requests, nil
+}
+
+// Function parseHARRequest converts the request of a HAR entry. Cookies are taken from the Cookie header, or from
+// the cookies of the entry if it has none. Form bodies recorded only as parameters are encoded again.
+func parseHARRequest(harReq harImportRequest) (Request, error) {
+	target, err := url.Parse(harReq.URL)
+	if err != nil {
+		return Request{}, fmt.Errorf("invalid URL %q: %v", harReq.URL, err)
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return Request{}, fmt.Errorf("unsupported scheme %q", target.Scheme)
+	}
+	t := Request{Method: harReq.Method, URL: harReq.URL}
+
+	cookieHeader := false
+	for _, header := range harReq.Headers {
+		name := http.CanonicalHeaderKey(header.Name)
+		if name == "Cookie" {
+			cookieHeader = true
+			for _, cookie := range strings.Split(header.Value, ";") {
+				if cookie = strings.TrimSpace(cookie); cookie != "" {
+					t.Cookies = append(t.Cookies, cookie)
+				}
+			}
+			continue
+		}
+		// HTTP/2 pseudo-headers, such as :authority, are set by the client
+		if strings.HasPrefix(name, ":") || rawSkippedHeaders[name] {
+			continue
+		}
+		t.Headers = append(t.Headers, fmt.Sprintf("%s: %s", name, header.Value))
+	}
+	if !cookieHeader {
+		for _, cookie := range harReq.Cookies {
+			t.Cookies = append(t.Cookies, fmt.Sprintf("%s=%s", cookie.Name, cookie.Value))
+		}
+	}
+
+	if data := harReq.PostData; data != nil {
+		t.Body = data.Text
+		if t.Body == "" && len(data.Params) > 0 {
+			form := make([]string, 0, len(data.Params))
+			for _, param := range data.Params {
+				form = append(form, url.QueryEscape(param.Name)+"="+url.QueryEscape(param.Value))
+			}
+			t.Body = strings.Join(form, "&")
+		}
+	}
+	return t, nil
+}
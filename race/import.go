@@ -0,0 +1,82 @@
+package race
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// ImportRequests fills in the requests of the configuration that are taken from other sources, such as raw HTTP
+// request files, HAR files and Burp Suite XML files. Relative paths are read from dir, which is usually the directory
+// of the configuration file, as are the wordlist and CSV files of template variables. A source may provide several
+// requests, which each take the place of the request that named it.
+// Requests must be imported before the configuration is run.
+func ImportRequests(config *Configuration, dir string) error {
+	var requests []Request
+	for i, t := range config.Requests {
+		if t.Wordlist != "" {
+			t.Wordlist = resolvePath(dir, t.Wordlist)
+		}
+		if t.CSV != "" {
+			t.CSV = resolvePath(dir, t.CSV)
+		}
+
+		var imported []Request
+		var err error
+		switch {
+		case t.RawRequest != "":
+			err = importRawRequest(&t, dir)
+			imported = []Request{t}
+		case t.HAR != "":
+			imported, err = importHAR(t, dir)
+		case t.Burp != "":
+			imported, err = importBurp(t, dir)
+		default:
+			imported = []Request{t}
+		}
+		if err != nil {
+			return fmt.Errorf("request #%d: %v", i+1, err)
+		}
+		requests = append(requests, imported...)
+	}
+	config.Requests = requests
+	return nil
+}
+
+// Function importSource returns the file that a request is still to be imported from, if any.
+func (t Request) importSource() string {
+	switch {
+	case t.RawRequest != "":
+		return t.RawRequest
+	case t.HAR != "":
+		return t.HAR
+	case t.Burp != "":
+		return t.Burp
+	}
+	return ""
+}
+
+// Function withImported returns the target with the fields of the imported request that it does not set itself.
+// The headers and cookies of the imported request are added before those of the target. The HTTP version that the
+// request was recorded with is not kept, so that it is sent over HTTP/1.1 unless the target sets http2 itself.
+func (t Request) withImported(parsed Request) Request {
+	if t.Method == "" {
+		t.Method = parsed.Method
+	}
+	if t.URL == "" {
+		t.URL = parsed.URL
+	}
+	if t.Body == "" {
+		t.Body = parsed.Body
+	}
+	t.Headers = append(parsed.Headers, t.Headers...)
+	t.Cookies = append(parsed.Cookies, t.Cookies...)
+	return t
+}
+
+// Function resolvePath returns the path relative to dir, unless it is absolute.
+func resolvePath(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
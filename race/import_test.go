@@ -0,0 +1,185 @@
+package race
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"reflect"
+	"testing"
+)
+
+func TestParseRawRequest(t *testing.T) {
+	tests := []struct {
+		name         string
+		raw          string
+		scheme, host string
+		want         Request
+	}{
+		{
+			name: "bare line feeds",
+			raw:  "\nPOST /pay?x=1 HTTP/1.1\nHost: shop.test\nUser-Agent: ua\nCookie: a=1; b=2\nContent-Type: text/plain\nContent-Length: 99\n\nval=1000\n\n",
+			want: Request{
+				Method:  "POST",
+				URL:     "https://shop.test/pay?x=1",
+				Headers: []string{"User-Agent: ua", "Content-Type: text/plain"},
+				Cookies: []string{"a=1", "b=2"},
+				Body:    "val=1000\n\n",
+			},
+		},
+		{
+			name: "body ending in a line break",
+			raw:  "POST / HTTP/1.1\r\nHost: shop.test\r\nContent-Length: 10\r\n\r\nval=1000\r\n",
+			want: Request{Method: "POST", URL: "https://shop.test/", Body: "val=1000\r\n"},
+		},
+		{
+			name:   "scheme and host given",
+			raw:    "GET / HTTP/1.1\r\nHost: shop.test\r\nAccept-Encoding: gzip\r\nConnection: close\r\n\r\n",
+			scheme: "http",
+			host:   "127.0.0.1:8080",
+			want:   Request{Method: "GET", URL: "http://127.0.0.1:8080/"},
+		},
+		{
+			name: "absolute target",
+			raw:  "GET http://proxied.test/a HTTP/1.1\r\nHost: other.test\r\n\r\n",
+			want: Request{Method: "GET", URL: "http://proxied.test/a"},
+		},
+		{
+			name: "HTTP/2 request line",
+			raw:  "GET /a HTTP/2\r\nHost: shop.test\r\nX-B: 2\r\nX-A: 1\r\nX-B: 3\r\n\r\n",
+			want: Request{Method: "GET", URL: "https://shop.test/a", Headers: []string{"X-B: 2", "X-B: 3", "X-A: 1"}},
+		},
+		{
+			name: "chunked body",
+			raw:  "POST / HTTP/1.1\r\nHost: shop.test\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nabc\r\n2\r\nde\r\n0\r\n\r\n",
+			want: Request{Method: "POST", URL: "https://shop.test/", Body: "abcde"},
+		},
+	}
+	for _, test := range tests {
+		got, err := parseRawRequest([]byte(test.raw), test.scheme, test.host)
+		if err != nil {
+			t.Errorf("%s: parseRawRequest returned error: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: parseRawRequest = %+v, want %+v", test.name, got, test.want)
+		}
+	}
+
+	for _, raw := range []string{
+		"not a request",
+		"GET / HTTP/1.1\r\n\r\n",
+		"GET ftp://files.test/ HTTP/1.1\r\nHost: files.test\r\n\r\n",
+	} {
+		if _, err := parseRawRequest([]byte(raw), "", ""); err == nil {
+			t.Errorf("parseRawRequest(%q) returned no error", raw)
+		}
+	}
+}
+
+func TestParseHARRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		har  string
+		want Request
+	}{
+		{
+			name: "headers and cookie header",
+			har: `{"method": "POST", "url": "https://shop.test/pay",
+				"headers": [{"name": ":authority", "value": "shop.test"}, {"name": "content-type", "value": "text/plain"},
+					{"name": "accept-encoding", "value": "br"}, {"name": "cookie", "value": "a=1; b=2"}],
+				"cookies": [{"name": "a", "value": "1"}, {"name": "b", "value": "2"}],
+				"postData": {"mimeType": "text/plain", "text": "val=1000"}}`,
+			want: Request{
+				Method:  "POST",
+				URL:     "https://shop.test/pay",
+				Headers: []string{"Content-Type: text/plain"},
+				Cookies: []string{"a=1", "b=2"},
+				Body:    "val=1000",
+			},
+		},
+		{
+			name: "cookies without header",
+			har:  `{"method": "GET", "url": "http://shop.test/", "cookies": [{"name": "s", "value": "x"}]}`,
+			want: Request{Method: "GET", URL: "http://shop.test/", Cookies: []string{"s=x"}},
+		},
+		{
+			name: "form parameters",
+			har: `{"method": "POST", "url": "http://shop.test/",
+				"postData": {"mimeType": "application/x-www-form-urlencoded", "params": [{"name": "a b", "value": "1&2"}, {"name": "c", "value": ""}]}}`,
+			want: Request{Method: "POST", URL: "http://shop.test/", Body: "a+b=1%262&c="},
+		},
+	}
+	for _, test := range tests {
+		var harReq harImportRequest
+		if err := json.Unmarshal([]byte(test.har), &harReq); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		got, err := parseHARRequest(harReq)
+		if err != nil {
+			t.Errorf("%s: parseHARRequest returned error: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: parseHARRequest = %+v, want %+v", test.name, got, test.want)
+		}
+	}
+
+	for _, u := range []string{"ws://shop.test/", "http://[::1"} {
+		if _, err := parseHARRequest(harImportRequest{Method: "GET", URL: u}); err == nil {
+			t.Errorf("parseHARRequest(%q) returned no error", u)
+		}
+	}
+}
+
+func TestParseBurpItem(t *testing.T) {
+	raw := "POST /pay HTTP/1.1\r\nHost: shop.test\r\nContent-Length: 8\r\n\r\nval=1000"
+	encoded := base64.StdEncoding.EncodeToString([]byte(raw))
+	tests := []struct {
+		name         string
+		item         string
+		scheme, host string
+		url          string
+	}{
+		{
+			name: "default port",
+			item: `<host>shop.test</host><port>443</port><protocol>https</protocol><request base64="true">` + encoded + `</request>`,
+			url:  "https://shop.test/pay",
+		},
+		{
+			name: "other port",
+			item: `<host>shop.test</host><port>8080</port><protocol>http</protocol><request base64="false"><![CDATA[` + raw + `]]></request>`,
+			url:  "http://shop.test:8080/pay",
+		},
+		{
+			name:   "scheme and host given",
+			item:   `<host>shop.test</host><port>443</port><protocol>https</protocol><request base64="true">` + encoded + `</request>`,
+			scheme: "http",
+			host:   "127.0.0.1:8080",
+			url:    "http://127.0.0.1:8080/pay",
+		},
+	}
+	for _, test := range tests {
+		var item burpItem
+		if err := xml.Unmarshal([]byte("<item>"+test.item+"</item>"), &item); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		got, err := parseBurpItem(item, test.scheme, test.host)
+		if err != nil {
+			t.Errorf("%s: parseBurpItem returned error: %v", test.name, err)
+			continue
+		}
+		if got.Method != "POST" || got.URL != test.url || got.Body != "val=1000" {
+			t.Errorf("%s: parseBurpItem = %+v, want POST %s with body val=1000", test.name, got, test.url)
+		}
+	}
+
+	for _, request := range []string{`<request base64="false"></request>`, `<request base64="true">!!</request>`} {
+		var item burpItem
+		if err := xml.Unmarshal([]byte("<item><host>shop.test</host>"+request+"</item>"), &item); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := parseBurpItem(item, "", ""); err == nil {
+			t.Errorf("parseBurpItem(%s) returned no error", request)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package race
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Function sendLastByte sends a single request over its own connection, using last-byte synchronization.
+// The request is written in full except for its final byte, after which the worker reports that it is
+// ready and waits for the start channel to be closed. The final byte is then written and the response read.
+// Redirects are never followed in this mode, as the HTTP client is bypassed.
+func (r *Runner) sendLastByte(ctx context.Context, t Request, tURL *url.URL, ready *sync.WaitGroup, start <-chan struct{}) (ResponseInfo, error) {
+	// Mark the worker as ready exactly once, even if it fails before reaching the barrier,
+	// so that the other workers are not held back forever.
+	var once sync.Once
+	markReady := func() {
+		once.Do(ready.Done)
+	}
+	defer markReady()
+
+	req, err := r.buildRequest(t, tURL)
+	if err != nil {
+		return ResponseInfo{}, fmt.Errorf("error in forming request: %v", err)
+	}
+	// Each request uses its own connection, which is not reused afterwards
+	req.Close = true
+
+	// Open the connection ahead of time, so that only the final byte is left to send
+	conn, proxied, err := r.dialTarget(ctx, tURL, false)
+	if err != nil {
+		return ResponseInfo{}, err
+	}
+	defer conn.Close()
+	defer closeOnDone(ctx, conn)()
+	conn.SetDeadline(time.Now().Add(t.requestTimeout()))
+
+	// Serialize the request. Plain HTTP requests sent through a proxy use the absolute form.
+	var payload bytes.Buffer
+	if proxied {
+		err = req.WriteProxy(&payload)
+	} else {
+		err = req.Write(&payload)
+	}
+	if err != nil {
+		return ResponseInfo{}, fmt.Errorf("error in serializing request: %v", err)
+	}
+	raw := payload.Bytes()
+
+	// Write everything but the final byte, then wait at the barrier
+	var timing RequestTiming
+	timing.FirstByteSent = time.Now()
+	if _, err := conn.Write(raw[:len(raw)-1]); err != nil {
+		return ResponseInfo{}, fmt.Errorf("error in writing request: %v", err)
+	}
+	markReady()
+	select {
+	case <-start:
+	case <-ctx.Done():
+		return ResponseInfo{}, ctx.Err()
+	}
+
+	// Release the final byte
+	timing.Start = time.Now()
+	conn.SetDeadline(timing.Start.Add(t.requestTimeout()))
+	if _, err := conn.Write(raw[len(raw)-1:]); err != nil {
+		return ResponseInfo{}, fmt.Errorf("error in writing final byte: %v", err)
+	}
+	timing.LastByteSent = time.Now()
+	r.emit(EventSent, t, Event{})
+
+	// Wait for the response to begin
+	reader := bufio.NewReader(conn)
+	if _, err := reader.Peek(1); err != nil {
+		return ResponseInfo{}, fmt.Errorf("error in reading response: %v", err)
+	}
+	timing.FirstByteRecvd = time.Now()
+
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return ResponseInfo{}, fmt.Errorf("error in reading response: %v", err)
+	}
+	// Read the body now, as the connection is closed when this function returns
+	if _, err := ReadResponseBody(resp); err != nil {
+		return ResponseInfo{}, fmt.Errorf("error in reading response body: %v", err)
+	}
+	timing.Done = time.Now()
+
+	return ResponseInfo{Response: resp, Target: t, Timing: timing}, nil
+}
+
+// Function dialTarget opens a connection to the target's host, tunnelling through the configured proxy if
+// one is set. TLS is negotiated for https targets, without verifying certificates. When http2 is set, the
+// connection is always tunnelled through the proxy, and HTTP/2 must be negotiated over TLS.
+// Returns whether the connection goes to a proxy directly, in which case requests must be written in proxy form.
+// The attempt is abandoned if ctx is done.
+func (r *Runner) dialTarget(ctx context.Context, tURL *url.URL, http2 bool) (conn net.Conn, proxied bool, err error) {
+	dialer := net.Dialer{Timeout: 30 * time.Second}
+	targetAddr := hostPort(tURL)
+
+	if r.Config.Proxy == "" {
+		conn, err = dialer.DialContext(ctx, "tcp", targetAddr)
+		if err != nil {
+			return nil, false, fmt.Errorf("error in connecting to %s: %v", targetAddr, err)
+		}
+		// Interrupt the handshake below if ctx is done
+		defer closeOnDone(ctx, conn)()
+	} else {
+		proxyURL, err := url.Parse(r.Config.Proxy)
+		if err != nil || (proxyURL.Scheme != "http" && proxyURL.Scheme != "https") || proxyURL.Host == "" {
+			return nil, false, fmt.Errorf("invalid proxy URL %q", r.Config.Proxy)
+		}
+		conn, err = dialer.DialContext(ctx, "tcp", hostPort(proxyURL))
+		if err != nil {
+			return nil, false, fmt.Errorf("error in connecting to proxy: %v", err)
+		}
+		// Interrupt the handshakes below if ctx is done
+		defer closeOnDone(ctx, conn)()
+
+		if proxyURL.Scheme == "https" {
+			proxyConn := tls.Client(conn, &tls.Config{
+				InsecureSkipVerify: true,
+				ServerName:         proxyURL.Hostname(),
+			})
+			if err := proxyConn.Handshake(); err != nil {
+				conn.Close()
+				return nil, false, fmt.Errorf("error in TLS handshake with proxy: %v", err)
+			}
+			conn = proxyConn
+		}
+
+		// Plain HTTP/1.1 requests are sent straight to the proxy
+		if tURL.Scheme != "https" && !http2 {
+			return conn, true, nil
+		}
+
+		// Everything else is tunnelled through the proxy
+		if err := connectTunnel(conn, targetAddr); err != nil {
+			conn.Close()
+			return nil, false, err
+		}
+	}
+
+	if tURL.Scheme == "https" {
+		tlsConfig := &tls.Config{
+			InsecureSkipVerify: true,
+			ServerName:         tURL.Hostname(),
+		}
+		if http2 {
+			tlsConfig.NextProtos = []string{"h2"}
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, false, fmt.Errorf("error in TLS handshake with %s: %v", targetAddr, err)
+		}
+		if http2 && tlsConn.ConnectionState().NegotiatedProtocol != "h2" {
+			conn.Close()
+			return nil, false, fmt.Errorf("%s does not support HTTP/2", targetAddr)
+		}
+		conn = tlsConn
+	}
+
+	return conn, false, nil
+}
+
+// Function closeOnDone closes conn if ctx is done before the returned function is called,
+// interrupting any reads or writes in progress.
+func closeOnDone(ctx context.Context, conn net.Conn) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+	}
+}
+
+// Function connectTunnel asks the proxy on the other end of conn to open a tunnel to addr.
+func connectTunnel(conn net.Conn, addr string) error {
+	fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", addr, addr)
+	resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: "CONNECT"})
+	if err != nil {
+		return fmt.Errorf("error in reading proxy CONNECT response: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("proxy refused CONNECT to %s: %s", addr, resp.Status)
+	}
+	return nil
+}
+
+// Function hostPort returns the host and port of a URL, using the default port for its scheme if none is given.
+func hostPort(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == "https" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
@@ -0,0 +1,17 @@
+package race
+
+import (
+	"context"
+	"net/url"
+	"testing"
+)
+
+func TestDialTargetInvalidProxy(t *testing.T) {
+	target := &url.URL{Scheme: "http", Host: "127.0.0.1:1"}
+	for _, proxy := range []string{"http://[::1", "socks5://127.0.0.1:1080", "127.0.0.1:8080", "http://"} {
+		r := NewRunner(Configuration{Proxy: proxy})
+		if _, _, err := r.dialTarget(context.Background(), target, false); err == nil {
+			t.Errorf("dialTarget through proxy %q returned no error", proxy)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package race
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Normalization holds the rules applied to the responses of a request before they are compared, so that dynamic
+// content such as CSRF tokens, timestamps and request IDs does not make every response unique.
+// The responses themselves are reported unchanged.
+type Normalization struct {
+	Replace        []Replacement `json:"replace"`
+	IgnoreJSON     []string      `json:"ignore_json"`     // JSON pointers (RFC 6901) removed from JSON bodies. A "*" token matches every key or element.
+	IgnoreHeaders  []string      `json:"ignore_headers"`  // Headers left out when comparing headers
+	CompareHeaders bool          `json:"compare_headers"` // Compare response headers as well as bodies
+}
+
+// Replacement replaces every match of a regular expression in the response body.
+type Replacement struct {
+	Pattern string `json:"pattern"`
+	With    string `json:"with"` // May refer to submatches, as in regexp.Regexp.ReplaceAllString
+}
+
+// Function normalizesBody reports whether the response bodies of the target are changed before comparison,
+// which makes their length meaningless.
+func (t Request) normalizesBody() bool {
+	n := t.Normalize
+	return t.Compare == CompareJSON || n != nil && (len(n.Replace) > 0 || len(n.IgnoreJSON) > 0)
+}
+
+// Function compileNormalization checks the comparison mode and normalization rules of every target, and compiles their patterns.
+func (r *Runner) compileNormalization() error {
+	r.patterns = make(map[string]*regexp.Regexp)
+	for _, t := range r.Config.Requests {
+		switch t.Compare {
+		case "", CompareBody:
+			if len(t.JSONInclude) > 0 || len(t.JSONExclude) > 0 {
+				return fmt.Errorf("JSON pointers to include or exclude for %s require compare = %q", t.URL, CompareJSON)
+			}
+		case CompareJSON:
+		default:
+			return fmt.Errorf("Unknown comparison mode %q for %s. Supported modes: %q, %q.", t.Compare, t.URL, CompareBody, CompareJSON)
+		}
+		for _, pointer := range append(append([]string{}, t.JSONInclude...), t.JSONExclude...) {
+			if _, err := parseJSONPointer(pointer); err != nil {
+				return fmt.Errorf("Invalid JSON pointer %q for %s: %v", pointer, t.URL, err)
+			}
+		}
+
+		if t.Normalize == nil {
+			continue
+		}
+		for _, rep := range t.Normalize.Replace {
+			re, err := regexp.Compile(rep.Pattern)
+			if err != nil {
+				return fmt.Errorf("Invalid normalization pattern %q for %s: %v", rep.Pattern, t.URL, err)
+			}
+			r.patterns[rep.Pattern] = re
+		}
+		for _, pointer := range t.Normalize.IgnoreJSON {
+			if _, err := parseJSONPointer(pointer); err != nil {
+				return fmt.Errorf("Invalid JSON pointer %q for %s: %v", pointer, t.URL, err)
+			}
+		}
+	}
+	return nil
+}
+
+// responseKey is the normalized form of a response, compared between responses to find the unique ones.
+type responseKey struct {
+	head   string // Status code and compared headers, which must always match
+	length int64
+	body   string
+}
+
+// Function String returns the key as a single string, for indexing.
+func (k responseKey) String() string {
+	return fmt.Sprintf("%s\n%d\n%s", k.head, k.length, k.body)
+}
+
+// Function responseKey returns the normalized form of a response, after applying the comparison mode and
+// normalization rules of the target that received the response.
+func (r *Runner) responseKey(t Request, data UniqueResponseData) responseKey {
+	n := t.Normalize
+
+	// Content length only matters if the body is compared as it was received
+	length := data.Length
+	if t.normalizesBody() {
+		length = -1
+	}
+
+	var headers string
+	if n != nil && n.CompareHeaders {
+		headers = headerKey(data.Headers, n.IgnoreHeaders)
+	}
+
+	return responseKey{
+		head:   fmt.Sprintf("%d\n%s", data.StatusCode, headers),
+		length: length,
+		body:   r.normalizeBody(t, data.Body),
+	}
+}
+
+// Function normalizeBody applies the target's comparison mode and normalization rules to a response body.
+// JSON bodies are canonicalized and their ignored values removed first, then the replacements are applied in order.
+func (r *Runner) normalizeBody(t Request, body string) string {
+	n := t.Normalize
+	if n == nil {
+		n = &Normalization{}
+	}
+
+	if t.Compare == CompareJSON || len(n.IgnoreJSON) > 0 {
+		// Bodies that are not JSON are compared as they are
+		if v, err := decodeJSON(body); err == nil {
+			for _, pointer := range append(append([]string{}, n.IgnoreJSON...), t.JSONExclude...) {
+				tokens, _ := parseJSONPointer(pointer)
+				v = removeJSONPointer(v, tokens)
+			}
+			if len(t.JSONInclude) > 0 {
+				v = selectJSONPointers(v, t.JSONInclude)
+			}
+			if b, err := encodeJSON(v); err == nil {
+				body = string(b)
+			}
+		}
+	}
+
+	for _, rep := range n.Replace {
+		body = r.patterns[rep.Pattern].ReplaceAllString(body, rep.With)
+	}
+
+	return body
+}
+
+// Function headerKey returns the response headers as a single string, in a fixed order, leaving out the ignored headers.
+func headerKey(headers http.Header, ignore []string) string {
+	ignored := make(map[string]bool, len(ignore))
+	for _, name := range ignore {
+		ignored[http.CanonicalHeaderKey(name)] = true
+	}
+
+	var names []string
+	for name := range headers {
+		if !ignored[http.CanonicalHeaderKey(name)] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var key bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&key, "%s: %s\n", http.CanonicalHeaderKey(name), strings.Join(headers[name], ", "))
+	}
+	return key.String()
+}
+
+// Function decodeJSON parses a JSON document, keeping numbers exactly as they were written.
+func decodeJSON(body string) (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	// Reject trailing data after the document
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected data after JSON document")
+	}
+	return v, nil
+}
+
+// Function encodeJSON serializes a decoded JSON document in canonical form: compact, with object keys sorted.
+func encodeJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// Function parseJSONPointer splits a JSON pointer into its reference tokens. The empty pointer refers to the whole document.
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("must be empty or start with \"/\"")
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+// Function selectJSONPointers returns a document holding only the values referred to by the pointers, keyed by pointer.
+// Pointers that refer to nothing are left out.
+func selectJSONPointers(v interface{}, pointers []string) interface{} {
+	selected := make(map[string]interface{}, len(pointers))
+	for _, pointer := range pointers {
+		tokens, _ := parseJSONPointer(pointer)
+		if value, ok := selectJSONPointer(v, tokens); ok {
+			selected[pointer] = value
+		}
+	}
+	return selected
+}
+
+// Function selectJSONPointer returns the value referred to by the tokens in a decoded JSON document, and whether it exists.
+// A "*" token selects the matching values of every key or element, as an object or array.
+func selectJSONPointer(v interface{}, tokens []string) (interface{}, bool) {
+	if len(tokens) == 0 {
+		return v, true
+	}
+	token, rest := tokens[0], tokens[1:]
+
+	switch node := v.(type) {
+	case map[string]interface{}:
+		if token == "*" {
+			matches := make(map[string]interface{})
+			for key, child := range node {
+				if value, ok := selectJSONPointer(child, rest); ok {
+					matches[key] = value
+				}
+			}
+			return matches, true
+		}
+		child, ok := node[token]
+		if !ok {
+			return nil, false
+		}
+		return selectJSONPointer(child, rest)
+	case []interface{}:
+		if token == "*" {
+			matches := []interface{}{}
+			for _, child := range node {
+				if value, ok := selectJSONPointer(child, rest); ok {
+					matches = append(matches, value)
+				}
+			}
+			return matches, true
+		}
+		i, err := strconv.Atoi(token)
+		if err != nil || i < 0 || i >= len(node) {
+			return nil, false
+		}
+		return selectJSONPointer(node[i], rest)
+	}
+	return nil, false
+}
+
+// Function removeJSONPointer removes the value referred to by the tokens from a decoded JSON document, and returns the document.
+// Removing the whole document leaves null in its place.
+func removeJSONPointer(v interface{}, tokens []string) interface{} {
+	if len(tokens) == 0 {
+		return nil
+	}
+	token, rest := tokens[0], tokens[1:]
+
+	switch node := v.(type) {
+	case map[string]interface{}:
+		for key, child := range node {
+			if token != "*" && token != key {
+				continue
+			}
+			if len(rest) == 0 {
+				delete(node, key)
+			} else {
+				node[key] = removeJSONPointer(child, rest)
+			}
+		}
+	case []interface{}:
+		if token == "*" {
+			if len(rest) == 0 {
+				return []interface{}{}
+			}
+			for i, child := range node {
+				node[i] = removeJSONPointer(child, rest)
+			}
+			return node
+		}
+		i, err := strconv.Atoi(token)
+		if err != nil || i < 0 || i >= len(node) {
+			return node
+		}
+		if len(rest) == 0 {
+			return append(node[:i], node[i+1:]...)
+		}
+		node[i] = removeJSONPointer(node[i], rest)
+	}
+	return v
+}
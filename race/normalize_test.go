@@ -0,0 +1,204 @@
+package race
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestNormalizeBody(t *testing.T) {
+	tests := []struct {
+		name   string
+		target Request
+		body   string
+		want   string
+	}{
+		{
+			name: "no rules",
+			body: `{"b": 1, "a": 2}`,
+			want: `{"b": 1, "a": 2}`,
+		},
+		{
+			name:   "replacements in order",
+			target: Request{Normalize: &Normalization{Replace: []Replacement{{Pattern: `token=\w+`, With: "token=X"}, {Pattern: `X`, With: "Y"}}}},
+			body:   "token=abc123; id=1",
+			want:   "token=Y; id=1",
+		},
+		{
+			name:   "replacement with submatch",
+			target: Request{Normalize: &Normalization{Replace: []Replacement{{Pattern: `(\d{4})-\d\d-\d\d`, With: "$1-MM-DD"}}}},
+			body:   "at 2017-06-01",
+			want:   "at 2017-MM-DD",
+		},
+		{
+			name:   "ignored JSON values",
+			target: Request{Normalize: &Normalization{IgnoreJSON: []string{"/id", "/items/*/at"}}},
+			body:   `{"id": 7, "items": [{"at": 1, "n": "a"}, {"at": 2, "n": "b"}]}`,
+			want:   `{"items":[{"n":"a"},{"n":"b"}]}`,
+		},
+		{
+			name:   "ignored array element",
+			target: Request{Normalize: &Normalization{IgnoreJSON: []string{"/0", "/9"}}},
+			body:   `[1, 2, 3]`,
+			want:   `[2,3]`,
+		},
+		{
+			name:   "ignored JSON values in a body that is not JSON",
+			target: Request{Normalize: &Normalization{IgnoreJSON: []string{"/id"}}},
+			body:   `{"id": 7} trailing`,
+			want:   `{"id": 7} trailing`,
+		},
+		{
+			name:   "ignored JSON values, then replacements",
+			target: Request{Normalize: &Normalization{IgnoreJSON: []string{"/at"}, Replace: []Replacement{{Pattern: `\d+`, With: "N"}}}},
+			body:   `{"at": "12:00", "n": 3}`,
+			want:   `{"n":N}`,
+		},
+	}
+	for _, test := range tests {
+		test.target.URL = "http://h/"
+		r := NewRunner(Configuration{Requests: []Request{test.target}})
+		if err := r.compileNormalization(); err != nil {
+			t.Errorf("%s: compileNormalization returned error: %v", test.name, err)
+			continue
+		}
+		if body := r.normalizeBody(r.Config.Requests[0], test.body); body != test.want {
+			t.Errorf("%s: normalizeBody(%q) = %q, want %q", test.name, test.body, body, test.want)
+		}
+	}
+}
+
+func TestResponseKey(t *testing.T) {
+	a := UniqueResponseData{StatusCode: 200, Length: 5, Body: "id=1", Headers: http.Header{"Date": {"Mon"}, "X-Id": {"1"}}}
+	b := UniqueResponseData{StatusCode: 200, Length: 6, Body: "id=22", Headers: http.Header{"Date": {"Tue"}, "X-Id": {"1"}}}
+	c := UniqueResponseData{StatusCode: 200, Length: 6, Body: "id=22", Headers: http.Header{"Date": {"Tue"}, "X-Id": {"2"}}}
+	digits := []Replacement{{Pattern: `\d+`, With: "N"}}
+
+	tests := []struct {
+		name      string
+		normalize *Normalization
+		x, y      UniqueResponseData
+		same      bool
+	}{
+		{"bodies differ", nil, a, b, false},
+		{"bodies normalized, so their lengths are not compared", &Normalization{Replace: digits}, a, b, true},
+		{"headers not compared", &Normalization{Replace: digits}, a, c, true},
+		{"headers compared", &Normalization{Replace: digits, CompareHeaders: true}, a, b, false},
+		{"headers compared, and some ignored", &Normalization{Replace: digits, CompareHeaders: true, IgnoreHeaders: []string{"date"}}, a, b, true},
+		{"ignored headers, and others that differ", &Normalization{Replace: digits, CompareHeaders: true, IgnoreHeaders: []string{"date"}}, a, c, false},
+		{"status codes differ", &Normalization{Replace: digits}, a, UniqueResponseData{StatusCode: 500, Body: "id=1"}, false},
+	}
+	for _, test := range tests {
+		r := NewRunner(Configuration{Requests: []Request{{URL: "http://h/", Normalize: test.normalize}}})
+		if err := r.compileNormalization(); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		target := r.Config.Requests[0]
+		if same := r.responseKey(target, test.x) == r.responseKey(target, test.y); same != test.same {
+			t.Errorf("%s: responses grouped together: %v, want %v", test.name, same, test.same)
+		}
+	}
+}
+
+func TestCompileNormalization(t *testing.T) {
+	for _, n := range []*Normalization{
+		{Replace: []Replacement{{Pattern: "("}}},
+		{IgnoreJSON: []string{"id"}},
+	} {
+		r := NewRunner(Configuration{Requests: []Request{{URL: "http://h/", Normalize: n}}})
+		if err := r.compileNormalization(); err == nil {
+			t.Errorf("compileNormalization(%+v) returned no error", n)
+		}
+	}
+}
+
+func TestCompareJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		target Request
+		body   string
+		want   string
+	}{
+		{
+			name:   "key order and whitespace",
+			target: Request{Compare: CompareJSON},
+			body:   "{\"b\": 1,\n \"a\": [true, null]}",
+			want:   `{"a":[true,null],"b":1}`,
+		},
+		{
+			name:   "numbers as written",
+			target: Request{Compare: CompareJSON},
+			body:   `{"price": 1.50, "big": 12345678901234567890}`,
+			want:   `{"big":12345678901234567890,"price":1.50}`,
+		},
+		{
+			name:   "characters not escaped",
+			target: Request{Compare: CompareJSON},
+			body:   `{"html": "<b>&</b>"}`,
+			want:   `{"html":"<b>&</b>"}`,
+		},
+		{
+			name:   "included values",
+			target: Request{Compare: CompareJSON, JSONInclude: []string{"/status", "/missing"}},
+			body:   `{"status": "ok", "id": 7}`,
+			want:   `{"/status":"ok"}`,
+		},
+		{
+			name:   "included values of every element",
+			target: Request{Compare: CompareJSON, JSONInclude: []string{"/items/*/n"}},
+			body:   `{"items": [{"at": 1, "n": "a"}, {"at": 2}, {"n": "c"}]}`,
+			want:   `{"/items/*/n":["a","c"]}`,
+		},
+		{
+			name:   "escaped pointer",
+			target: Request{Compare: CompareJSON, JSONInclude: []string{"/a~1b/c~0d"}},
+			body:   `{"a/b": {"c~d": 1, "e": 2}}`,
+			want:   `{"/a~1b/c~0d":1}`,
+		},
+		{
+			name:   "excluded values",
+			target: Request{Compare: CompareJSON, JSONExclude: []string{"/id"}},
+			body:   `{"status": "ok", "id": 7}`,
+			want:   `{"status":"ok"}`,
+		},
+		{
+			name:   "body that is not JSON",
+			target: Request{Compare: CompareJSON, Normalize: &Normalization{Replace: []Replacement{{Pattern: `\d+`, With: "N"}}}},
+			body:   "error 500",
+			want:   "error N",
+		},
+	}
+	for _, test := range tests {
+		test.target.URL = "http://h/"
+		r := NewRunner(Configuration{Requests: []Request{test.target}})
+		if err := r.compileNormalization(); err != nil {
+			t.Errorf("%s: compileNormalization returned error: %v", test.name, err)
+			continue
+		}
+		if body := r.normalizeBody(r.Config.Requests[0], test.body); body != test.want {
+			t.Errorf("%s: normalizeBody(%q) = %q, want %q", test.name, test.body, body, test.want)
+		}
+	}
+
+	// JSON bodies that only differ in their formatting are grouped together, whatever their length
+	r := NewRunner(Configuration{Requests: []Request{{URL: "http://h/", Compare: CompareJSON}}})
+	if err := r.compileNormalization(); err != nil {
+		t.Fatal(err)
+	}
+	x := UniqueResponseData{StatusCode: 200, Length: 16, Body: `{"a": 1, "b": 2}`}
+	y := UniqueResponseData{StatusCode: 200, Length: 13, Body: `{"b":2,"a":1}`}
+	if r.responseKey(r.Config.Requests[0], x) != r.responseKey(r.Config.Requests[0], y) {
+		t.Errorf("JSON bodies %q and %q were not grouped together", x.Body, y.Body)
+	}
+
+	for _, target := range []Request{
+		{Compare: "xml"},
+		{JSONInclude: []string{"/id"}},
+		{Compare: CompareJSON, JSONExclude: []string{"id"}},
+	} {
+		target.URL = "http://h/"
+		r := NewRunner(Configuration{Requests: []Request{target}})
+		if err := r.compileNormalization(); err == nil {
+			t.Errorf("compileNormalization(%+v) returned no error", target)
+		}
+	}
+}
@@ -0,0 +1,700 @@
+// Package race tests web applications for race conditions, by sending a number of requests to one or more
+// targets at the same time, and comparing the responses for uniqueness.
+//
+// The package holds no global state, so that races can be run from other programs and tests:
+//
+//	result, err := race.Run(ctx, config)
+package race
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RedirectError is a custom error type for following redirects, and can be safely ignored
+type RedirectError struct {
+	RedirectRequest *http.Request
+}
+
+// Error method returns a string of the error
+func (err *RedirectError) Error() string {
+	return fmt.Sprintf("Redirect not followed to: %v", err.RedirectRequest.URL.String())
+}
+
+// ResponseInfo details information about responses received from targets. Uses *http.Response here for speed, as this struct is used in gathering data quickly back from targets (before comparison begins). This will later be parsed and converted into a UniqueResponseData object.
+type ResponseInfo struct {
+	Response *http.Response
+	Target   Request
+	Timing   RequestTiming
+
+	request int     // Index of the target in the configuration
+	sent    Request // Copy of the target that was sent, with its template variables expanded
+}
+
+// UniqueResponseInfo details information about unique responses received from targets
+type UniqueResponseInfo struct {
+	Response UniqueResponseData
+	Targets  []Request
+	Count    int
+	Timing   TimingSummary
+
+	Outliers     []Outlier // Responses grouped with this one for being similar, rather than the same
+	MoreOutliers int       // Number of responses grouped with this one beyond the outliers described
+	Diff         string    // Unified diff of the baseline response against this one, empty for the baseline itself
+	Successes    int       // Number of these responses that met the success matcher of their request
+
+	timings []RequestTiming // Timings of every request that received this response
+	key     responseKey     // Normalized form of the response, compared to find unique responses
+	tokens  *tokenBag       // Tokens of the normalized body, once compared by similarity
+}
+
+// maxOutliers is the number of distinct outliers described for each unique response.
+const maxOutliers = 10
+
+// Outlier describes the responses that were grouped with a unique response for being similar to it, but not the same.
+type Outlier struct {
+	Count      int     // Number of responses with this content
+	Similarity float64 // Similarity of the body to the unique response, from 0 to 1
+	Diff       string  // Unified diff of the unique response's body against the outlier's
+}
+
+// ResponseData is an easily consumable structure holding relevant unique response data
+type UniqueResponseData struct {
+	Body       string
+	StatusCode int
+	Length     int64
+	Protocol   string
+	Headers    http.Header
+	Location   string
+}
+
+// Result holds the outcome of a race test.
+type Result struct {
+	Responses   []UniqueResponseInfo // Unique responses received
+	Errors      []error              // Errors from individual requests, which do not stop the race
+	Spread      time.Duration        // Time between the first and last request being sent
+	Interrupted bool                 // The race was cancelled or timed out, and only holds the responses received until then
+	Baseline    int                  // Index of the unique response that the others are diffed against
+	Verdict     *Verdict             // Whether the race condition was exploited, if any request has a success matcher
+	Failed      int                  // Number of requests that failed without a response, including those abandoned when interrupted
+	Started     time.Time            // When the race test began
+	Duration    time.Duration        // Time taken by the whole race test, including the comparison of the responses
+
+	AssertionFailures []AssertionFailure // Assertions from the configuration that the result does not meet
+	ErrorsExceeded    bool               // More requests failed than the assertions allow
+
+	Exchanges []Exchange // Every response received, in the order they arrived, if the runner records them
+}
+
+// Exchange is a single request sent during a race, and the response that it received.
+type Exchange struct {
+	Request  int // Index of the target in the configuration
+	Unique   int // Index of the unique response that the response was grouped with
+	Timing   RequestTiming
+	Response *http.Response // The response received. Response.Request is the request as it was sent.
+	Body     []byte         // Body of the response, which has already been read
+	Sent     Request        // Copy of the target that was sent, with its template variables expanded
+}
+
+// Runner runs race tests for a r.Config. All of the state for a race is kept within a single call to Run.
+type Runner struct {
+	Config Configuration
+	// Logger receives progress messages. The standard logger is used if nil.
+	Logger *log.Logger
+	// Events receives progress events while the race runs, if set. It is called from several goroutines at once,
+	// and should return quickly, as requests wait on it.
+	Events func(Event)
+	// Record keeps every response received, and the request that it answered, in the result as Exchanges.
+	// Responses are otherwise only kept once for each unique response.
+	Record bool
+
+	patterns  map[string]*regexp.Regexp // Compiled normalization patterns
+	templates []*requestTemplate        // Values of the template variables of each target
+}
+
+// NewRunner returns a Runner for the configuration, with the default options set.
+func NewRunner(config Configuration) *Runner {
+	SetDefaults(&config)
+	return &Runner{Config: config}
+}
+
+// Run begins a race test for the configuration, using a new Runner.
+func Run(ctx context.Context, config Configuration) (*Result, error) {
+	return NewRunner(config).Run(ctx)
+}
+
+// Run begins the race test. Cancelling ctx, or reaching the configured timeout, stops the race early; the responses
+// received up to that point are still compared and returned, with the result marked as interrupted.
+// Returns an error if the race could not be started, and the unique responses and request errors otherwise.
+func (r *Runner) Run(ctx context.Context) (*Result, error) {
+	// Verify that config is present
+	if len(r.Config.Requests) == 0 {
+		// No targets specified
+		return nil, fmt.Errorf("No targets set. Minimum of 1 target required.")
+	}
+
+	// Verify that every request has been imported from its source
+	for _, t := range r.Config.Requests {
+		if source := t.importSource(); source != "" {
+			return nil, fmt.Errorf("The request in %q has not been imported. Requests are only imported when the configuration is read from a file.", source)
+		}
+	}
+
+	// Verify the proxy
+	if err := r.prepareAttack(); err != nil {
+		return nil, err
+	}
+
+	// Verify the synchronization mode
+	if r.Config.Sync != "" && r.Config.Sync != SyncLastByte {
+		return nil, fmt.Errorf("Unknown sync mode %q. Supported modes: %q.", r.Config.Sync, SyncLastByte)
+	}
+
+	// Verify the similarity threshold
+	if r.Config.Similarity < 0 || r.Config.Similarity > 1 {
+		return nil, fmt.Errorf("Similarity must be between 0 and 1, not %v.", r.Config.Similarity)
+	}
+
+	// Verify the normalization rules and success matchers
+	if err := r.compileNormalization(); err != nil {
+		return nil, err
+	}
+	if err := r.compileSuccess(); err != nil {
+		return nil, err
+	}
+
+	// Verify the template variables, and read the files that they take their values from
+	if err := r.compileTemplates(); err != nil {
+		return nil, err
+	}
+
+	// Verify the assertions
+	if r.Config.Assert != nil {
+		if err := r.Config.Assert.validate(r.Config); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Limit the duration of the whole race, if requested
+	if r.Config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(r.Config.Timeout)*time.Second)
+		defer cancel()
+	}
+
+	result := &Result{Started: time.Now()}
+
+	// Send the requests concurrently
+	r.logf("Requests begin.")
+	responses, errors, spread := r.sendRequests(ctx)
+	for err := range errors {
+		result.Errors = append(result.Errors, err)
+		result.Failed++
+	}
+	result.Spread = spread
+	if err := ctx.Err(); err != nil {
+		result.Interrupted = true
+		result.Errors = append(result.Errors, fmt.Errorf("Race interrupted (%v). Only the responses received so far are reported.", err))
+		r.logf("Requests interrupted.")
+	} else {
+		r.logf("Requests completed.")
+	}
+	r.logf("Spread between first and last request sent: %v", spread)
+
+	// Make sure all response bodies are closed- memory leaks otherwise
+	defer func() {
+		for respInfo := range responses {
+			respInfo.Response.Body.Close()
+		}
+	}()
+
+	// Compare the responses for uniqueness
+	uniqueResponses, successes, exchanges, errors := r.compareResponses(responses)
+	for err := range errors {
+		result.Errors = append(result.Errors, err)
+	}
+	result.Responses = uniqueResponses
+	result.Exchanges = exchanges
+	received := 0
+	for _, resp := range uniqueResponses {
+		received += resp.Count
+	}
+	result.Verdict = r.judge(successes, received)
+
+	// Requests abandoned when the race was interrupted failed as well, though their errors are left out
+	if result.Interrupted {
+		result.Failed = r.Config.Count*len(r.Config.Requests) - received
+	}
+
+	// Compare the unique responses to the baseline
+	if err := r.diffResponses(result); err != nil {
+		result.Errors = append(result.Errors, err)
+	}
+
+	// Check the result against the assertions
+	if r.Config.Assert != nil {
+		r.Config.Assert.check(result)
+	}
+	result.Duration = time.Since(result.Started)
+
+	return result, nil
+}
+
+// Function logf writes a progress message to the runner's logger.
+func (r *Runner) logf(format string, v ...interface{}) {
+	if r.Logger != nil {
+		r.Logger.Printf(format, v...)
+		return
+	}
+	log.Printf(format, v...)
+}
+
+// Function verbosef writes a progress message if verbose logging is enabled.
+func (r *Runner) verbosef(format string, v ...interface{}) {
+	if r.Config.Verbose {
+		r.logf("[VERBOSE] "+format, v...)
+	}
+}
+
+// Function prepareAttack checks the proxy, which defaults to an http proxy if no scheme is given, as in curl.
+// Returns an error if something went wrong.
+func (r *Runner) prepareAttack() error {
+	if r.Config.Proxy == "" {
+		return nil
+	}
+	proxy := r.Config.Proxy
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+	proxyURL, err := url.Parse(proxy)
+	if err != nil || proxyURL.Host == "" {
+		return fmt.Errorf("Invalid proxy URL %q.", r.Config.Proxy)
+	}
+	if proxyURL.Scheme != "http" && proxyURL.Scheme != "https" {
+		return fmt.Errorf("Proxy must be an http or https proxy, and specify the proper scheme (e.g. \"http://127.0.0.1:8080\")")
+	}
+	r.Config.Proxy = proxyURL.String()
+	return nil
+}
+
+// Function sendRequests takes care of sending the requests to the target concurrently.
+// Every request is prepared ahead of time (connections opened, or written up to the final byte), and
+// all of them are released together once ready. Also returns the spread between the first and last send.
+// Errors are passed back in a channel of errors. If the length is zero, there were no errors.
+// Requests still in progress are abandoned if ctx is done; their errors are left out, as they all share the same cause.
+func (r *Runner) sendRequests(ctx context.Context) (responses chan ResponseInfo, errors chan error, spread time.Duration) {
+	// Initialize the concurrency objects
+	var urlsInProgress sync.WaitGroup
+	responses = make(chan ResponseInfo, r.Config.Count*len(r.Config.Requests))
+	errors = make(chan error, r.Config.Count*len(r.Config.Requests))
+	urlsInProgress.Add(r.Config.Count * len(r.Config.Requests))
+
+	// Synchronization barrier. Every participant marks itself as ready once its requests have been
+	// prepared, and the start channel is closed once all of them are ready, releasing them together.
+	lastByte := r.Config.Sync == SyncLastByte
+	var ready sync.WaitGroup
+	start := make(chan struct{})
+	for _, target := range r.Config.Requests {
+		ready.Add(r.barrierParticipants(target))
+	}
+	go func() {
+		ready.Wait()
+		close(start)
+	}()
+
+	// Track when the first and last requests were sent, as responses are delivered
+	var sendMutex sync.Mutex
+	var firstSend, lastSend time.Time
+	deliver := func(request int, t Request, respInfo ResponseInfo) {
+		// Responses are compared by the target in the configuration, rather than by the copy that was sent
+		respInfo.request = request
+		respInfo.sent = respInfo.Target
+		respInfo.Target = t
+		sendMutex.Lock()
+		if firstSend.IsZero() || respInfo.Timing.Start.Before(firstSend) {
+			firstSend = respInfo.Timing.Start
+		}
+		if respInfo.Timing.Start.After(lastSend) {
+			lastSend = respInfo.Timing.Start
+		}
+		sendMutex.Unlock()
+		r.emit(EventReceived, respInfo.sent, Event{StatusCode: respInfo.Response.StatusCode})
+		responses <- respInfo
+	}
+	fail := func(t Request, err error) {
+		if ctx.Err() == nil {
+			r.emit(EventError, t, Event{Error: err.Error()})
+			errors <- err
+		}
+	}
+
+	// Send requests to multiple URLs (if present) the same number of times
+	for i, target := range r.Config.Requests {
+		go func(request int, t Request) {
+			// Expand the template variables of every copy, and cast each target URL to a URL type
+			copies := make([]requestCopy, r.Config.Count)
+			for index := range copies {
+				sent, err := r.templates[request].expand(t, index)
+				if err != nil {
+					err = fmt.Errorf("Error expanding the template variables of request #%v: %v", index, err)
+				} else if copies[index].url, err = url.Parse(sent.URL); err != nil {
+					err = fmt.Errorf("Error parsing URL %s: %v", sent.URL, err.Error())
+				}
+				if err != nil {
+					fail(t, err)
+					// Release the workers that will never be started for this target
+					ready.Add(-r.barrierParticipants(t))
+					urlsInProgress.Add(-r.Config.Count)
+					return
+				}
+				copies[index].Request = sent
+			}
+
+			// VERBOSE
+			if r.Config.Verbose {
+				r.verbosef("Sending %d %s requests to %s", r.Config.Count, t.Method, t.URL)
+				if r.Config.Proxy != "" {
+					r.verbosef("Proxy: %s", r.Config.Proxy)
+				}
+				if t.Body != "" {
+					r.verbosef("Request body: %s", t.Body)
+				}
+				if len(t.Cookies) > 0 {
+					r.verbosef("Request cookies: %v", t.Cookies)
+				}
+				if t.HTTP2 {
+					r.verbosef("Protocol: HTTP/2 (single packet)")
+				} else if lastByte {
+					r.verbosef("Synchronization: %s", SyncLastByte)
+				}
+			}
+
+			// HTTP/2 requests are all sent together by the single-packet engine
+			if t.HTTP2 {
+				defer urlsInProgress.Add(-r.Config.Count)
+				resps, errs := r.sendSinglePacket(ctx, copies, &ready, start)
+				for _, respInfo := range resps {
+					deliver(request, t, respInfo)
+				}
+				for _, err := range errs {
+					fail(t, err)
+				}
+				return
+			}
+
+			for i, c := range copies {
+				go func(index int, c requestCopy) {
+					// Ensure that the waitgroup element is returned
+					defer urlsInProgress.Done()
+
+					// Last-byte synchronization bypasses the HTTP client entirely
+					if lastByte {
+						respInfo, err := r.sendLastByte(ctx, c.Request, c.url, &ready, start)
+						if err != nil {
+							fail(c.Request, fmt.Errorf("Error in request #%v: %v\n", index, err))
+							return
+						}
+						deliver(request, t, respInfo)
+						return
+					}
+
+					respInfo, err := r.sendStandard(ctx, c.Request, c.url, &ready, start)
+					if err != nil {
+						fail(c.Request, fmt.Errorf("Error in request #%v: %v\n", index, err))
+						return
+					}
+					deliver(request, t, respInfo)
+				}(i, c)
+			}
+		}(i, target)
+	}
+
+	// Wait for the URLs to finish sending
+	urlsInProgress.Wait()
+
+	// VERBOSE
+	r.verbosef("Requests complete.")
+
+	// Close the response and error channels, so they don't block on the range read
+	close(responses)
+	close(errors)
+
+	spread = lastSend.Sub(firstSend)
+	return
+}
+
+// Function sendStandard sends a single request using the HTTP client. The client's connection is warmed up
+// (DNS, TCP and TLS) before the worker reports that it is ready, and the request is sent once the start channel is closed.
+func (r *Runner) sendStandard(ctx context.Context, t Request, tURL *url.URL, ready *sync.WaitGroup, start <-chan struct{}) (ResponseInfo, error) {
+	// Mark the worker as ready exactly once, even if it fails before reaching the barrier
+	var once sync.Once
+	markReady := func() {
+		once.Do(ready.Done)
+	}
+	defer markReady()
+
+	req, err := r.buildRequest(t, tURL)
+	if err != nil {
+		return ResponseInfo{}, fmt.Errorf("error in forming request: %v", err)
+	}
+
+	// Create the HTTP client, with its connection already open
+	client, closeClient, err := r.newWarmClient(ctx, t, tURL)
+	if err != nil {
+		return ResponseInfo{}, err
+	}
+	defer closeClient()
+	markReady()
+	select {
+	case <-start:
+	case <-ctx.Done():
+		return ResponseInfo{}, ctx.Err()
+	}
+
+	// Record the timings of the request as it is sent
+	var timing RequestTiming
+	req, timingDone := withTimingTrace(req.WithContext(ctx), &timing)
+
+	// Make the request
+	r.emit(EventSent, t, Event{})
+	timing.Start = time.Now()
+	resp, err := client.Do(req)
+	// Check the error type from the request
+	if err != nil {
+		if uErr, ok := err.(*url.Error); ok {
+			if rErr, ok2 := uErr.Err.(*RedirectError); ok2 {
+				// Redirect error
+				// VERBOSE
+				r.verbosef("%v", rErr)
+				// The response is still valid, though its body has already been closed
+				timingDone()
+				return ResponseInfo{Response: resp, Target: t, Timing: timing}, nil
+			}
+		}
+		return ResponseInfo{}, err
+	}
+
+	// Read the body now, so that the time the response was completed is known
+	if _, err := ReadResponseBody(resp); err != nil {
+		return ResponseInfo{}, fmt.Errorf("error in reading response body: %v", err)
+	}
+	timingDone()
+
+	return ResponseInfo{Response: resp, Target: t, Timing: timing}, nil
+}
+
+// Function barrierParticipants returns the number of participants a target adds to the synchronization barrier.
+// The HTTP/2 engine waits at the barrier once for all of its streams, while every other request waits on its own.
+func (r *Runner) barrierParticipants(t Request) int {
+	if t.HTTP2 {
+		return 1
+	}
+	return r.Config.Count
+}
+
+// Function buildRequest forms the HTTP request for a single target, including its cookies and custom headers.
+func (r *Runner) buildRequest(t Request, tURL *url.URL) (*http.Request, error) {
+	// Convert the request body to an io.Reader interface, to pass to the request.
+	// This must be done for every request, because any call to client.Do() will
+	// read the body contents on the first time, but not any subsequent requests.
+	requestBody := strings.NewReader(t.Body)
+
+	// Declare HTTP request method and URL
+	req, err := http.NewRequest(t.Method, tURL.String(), requestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	// TEMP- append cookies directly to the request
+	if len(t.Cookies) > 0 {
+		cookieStr := strings.Join(t.Cookies, ";")
+		req.Header.Add("Cookie", cookieStr)
+	}
+
+	// Track whether content-type header has been added
+	contentType := false
+
+	// Add custom headers to the request
+	for _, header := range t.Headers {
+		// Values may hold colons of their own, such as URLs
+		split := strings.SplitN(header, ":", 2)
+		if len(split) != 2 {
+			return nil, fmt.Errorf("invalid header %q, expected \"Name: value\"", header)
+		}
+		hKey := strings.TrimSpace(split[0])
+		hVal := strings.TrimSpace(split[1])
+		req.Header.Add(hKey, hVal)
+
+		// Check for Content-Type header
+		if strings.ToLower(hKey) == "content-type" {
+			contentType = true
+			r.verbosef("Content-Type Found!")
+		}
+	}
+
+	// Add content-type to POST requests (some applications require this to properly process POST requests)
+	// TODO: Find any bugs around other request types
+	if !contentType && t.Method == "POST" {
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	return req, nil
+}
+
+// Function compareResponses compares the responses returned from the requests,
+// and adds them to a map, where the key is an *http.Response, and the value is
+// the number of similar responses observed.
+// The number of successful responses to each target is counted as well, and every response is kept if recording.
+func (r *Runner) compareResponses(responses chan ResponseInfo) (uniqueResponses []UniqueResponseInfo, successes []int, exchanges []Exchange, errors chan error) {
+	// Initialize the channels
+	errors = make(chan error, len(responses))
+	successes = make([]int, len(r.Config.Requests))
+
+	// VERBOSE
+	r.verbosef("Unique response comparison begin.")
+
+	// Timings are reported relative to the first request released
+	var firstStart time.Time
+
+	// Index of the unique response matched by each distinct normalized response, and the outlier it was recorded as, if any
+	index := make(map[string]int)
+	outliers := make(map[string]int)
+
+	// Compare the responses, one at a time
+	for respInfo := range responses {
+		if firstStart.IsZero() || respInfo.Timing.Start.Before(firstStart) {
+			firstStart = respInfo.Timing.Start
+		}
+
+		// Read the response body
+		respBody, err := ReadResponseBody(respInfo.Response)
+		if err != nil {
+			errors <- fmt.Errorf("Error reading response body: %s", err.Error())
+
+			// Exit this loop
+			continue
+		}
+
+		// Create response data object to pass around
+		respData := UniqueResponseData{
+			Body:       string(respBody),
+			StatusCode: respInfo.Response.StatusCode,
+			Length:     respInfo.Response.ContentLength,
+			Protocol:   respInfo.Response.Proto,
+			Headers:    respInfo.Response.Header}
+		location, err := respInfo.Response.Location()
+		if err != http.ErrNoLocation {
+			respData.Location = location.String()
+		}
+		key := r.responseKey(respInfo.Target, respData)
+		success := r.isSuccess(respInfo.Target, respData)
+		if success {
+			successes[respInfo.request]++
+		}
+
+		// Look for a unique response with the same normalized status code, body content, and content length
+		i, match := index[key.String()]
+		if !match && r.Config.Similarity > 0 {
+			// Otherwise, look for one that is similar enough
+			var similarity float64
+			if i, similarity, match = r.closestResponse(uniqueResponses, key); match {
+				// Only the first outliers are described, to keep the output readable
+				outliers[key.String()] = -1
+				if len(uniqueResponses[i].Outliers) < maxOutliers {
+					outliers[key.String()] = len(uniqueResponses[i].Outliers)
+					uniqueResponses[i].Outliers = append(uniqueResponses[i].Outliers, Outlier{
+						Similarity: similarity,
+						Diff:       unifiedDiff("representative", "outlier", uniqueResponses[i].Response.Body, respData.Body),
+					})
+				}
+			}
+		}
+
+		if r.Record {
+			exchange := Exchange{
+				Request:  respInfo.request,
+				Unique:   i,
+				Timing:   respInfo.Timing,
+				Response: respInfo.Response,
+				Body:     respBody,
+				Sent:     respInfo.sent,
+			}
+			if !match {
+				exchange.Unique = len(uniqueResponses)
+			}
+			exchanges = append(exchanges, exchange)
+		}
+
+		if !match {
+			// Unique, add to unique responses
+			uniqueResponses = append(uniqueResponses, UniqueResponseInfo{
+				Count:    1,
+				Response: respData,
+				Targets:  []Request{respInfo.Target},
+				timings:  []RequestTiming{respInfo.Timing},
+				key:      key})
+			if success {
+				uniqueResponses[len(uniqueResponses)-1].Successes++
+			}
+			index[key.String()] = len(uniqueResponses) - 1
+			r.emit(EventUnique, respInfo.Target, Event{StatusCode: respData.StatusCode, Unique: len(uniqueResponses)})
+			continue
+		}
+
+		// Match found
+		index[key.String()] = i
+		compareResp := &uniqueResponses[i]
+		compareResp.Count++
+		compareResp.timings = append(compareResp.timings, respInfo.Timing)
+		if success {
+			compareResp.Successes++
+		}
+		if o, ok := outliers[key.String()]; ok {
+			if o < 0 {
+				compareResp.MoreOutliers++
+			} else {
+				compareResp.Outliers[o].Count++
+			}
+		}
+
+		// Check for the same request that generated this matched response (== unique request AND response)
+		reqMatch := false
+		// Iterate through all requests in comparison group and compare against current request being processed
+		for _, compareTarget := range compareResp.Targets {
+			if reflect.DeepEqual(compareTarget, respInfo.Target) {
+				// Request match found
+				reqMatch = true
+				break
+			}
+		}
+		if !reqMatch {
+			// Append the new target to the unique response
+			compareResp.Targets = append(compareResp.Targets, respInfo.Target)
+		}
+	}
+
+	// Summarize the timings of each unique response
+	for i := range uniqueResponses {
+		uniqueResponses[i].Timing = summarizeTimings(uniqueResponses[i].timings, firstStart)
+	}
+
+	// VERBOSE
+	r.verbosef("Unique response comparison complete.")
+
+	// Close the channels
+	close(errors)
+
+	return
+}
@@ -0,0 +1,41 @@
+package race
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPrepareAttack(t *testing.T) {
+	tests := []struct {
+		proxy string
+		want  string // Proxy once checked, or empty if it is rejected
+	}{
+		{"", ""},
+		{"http://127.0.0.1:8080", "http://127.0.0.1:8080"},
+		{"https://proxy.test", "https://proxy.test"},
+		{"127.0.0.1:8080", "http://127.0.0.1:8080"},
+		{"http://[::1", ""},
+		{"socks5://127.0.0.1:1080", ""},
+		{"http://", ""},
+	}
+	for _, test := range tests {
+		r := NewRunner(Configuration{Proxy: test.proxy})
+		err := r.prepareAttack()
+		switch {
+		case test.want == "" && test.proxy != "":
+			if err == nil {
+				t.Errorf("prepareAttack with proxy %q returned no error", test.proxy)
+			}
+		case err != nil:
+			t.Errorf("prepareAttack with proxy %q returned error: %v", test.proxy, err)
+		case r.Config.Proxy != test.want:
+			t.Errorf("prepareAttack with proxy %q set it to %q, want %q", test.proxy, r.Config.Proxy, test.want)
+		}
+	}
+
+	// The proxy is checked before any request is sent
+	config := Configuration{Proxy: "http://[::1", Requests: []Request{{Method: "GET", URL: "http://127.0.0.1:1/"}}}
+	if _, err := NewRunner(config).Run(context.Background()); err == nil {
+		t.Error("Run with an invalid proxy returned no error")
+	}
+}
@@ -0,0 +1,145 @@
+package race
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// rawHTTP2Version matches the HTTP/2 version on the request line of a raw request, as Burp Suite shows it,
+// which the HTTP/1.1 parser does not accept. Such requests are parsed, and sent, as HTTP/1.1.
+var rawHTTP2Version = regexp.MustCompile(`^([^\r\n]* )HTTP/2(\.0)?(\r?\n)`)
+
+// rawSkippedHeaders are the headers of a raw request that are not kept, as they are either set by the HTTP client
+// for each connection, or taken into other fields. Accept-Encoding is left for the client to set, so that
+// response bodies can be decoded for comparison.
+var rawSkippedHeaders = map[string]bool{
+	"Host":              true,
+	"Cookie":            true,
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+	"Keep-Alive":        true,
+	"Proxy-Connection":  true,
+	"Accept-Encoding":   true,
+}
+
+// ClientHeader reports whether a header is left out of requests imported from other tools, as it is either set by
+// the HTTP client for each request, or taken into other fields.
+func ClientHeader(name string) bool {
+	return rawSkippedHeaders[http.CanonicalHeaderKey(name)]
+}
+
+// Function importRawRequest fills in the target from its raw request file, read from dir if the path is relative.
+// Fields that are already set in the target are kept, while the headers and cookies of the raw request are added
+// before those of the target.
+func importRawRequest(t *Request, dir string) error {
+	raw, err := ioutil.ReadFile(resolvePath(dir, t.RawRequest))
+	if err != nil {
+		return fmt.Errorf("could not read raw request: %v", err)
+	}
+	parsed, err := parseRawRequest(raw, t.Scheme, t.Host)
+	if err != nil {
+		return fmt.Errorf("could not parse raw request %s: %v", t.RawRequest, err)
+	}
+
+	*t = t.withImported(parsed)
+	t.RawRequest, t.Scheme, t.Host = "", "", ""
+	return nil
+}
+
+// Function parseRawRequest parses a raw HTTP/1.1 request, as copied from an intercepting proxy, into a request.
+// The scheme defaults to https, and the host to that of the Host header. Cookies are taken from the Cookie header.
+// The body is everything after the blank line that ends the headers, as it is written, so that it can be edited
+// without updating the Content-Length header, which is set again when the request is sent. Chunked bodies are decoded.
+func parseRawRequest(raw []byte, scheme, host string) (Request, error) {
+	var t Request
+
+	// Raw requests are often saved with bare line feeds, or with blank lines before them
+	raw = bytes.TrimLeft(raw, "\r\n")
+	if m := rawHTTP2Version.FindSubmatchIndex(raw); m != nil {
+		raw = append(append(append([]byte(nil), raw[m[2]:m[3]]...), "HTTP/1.1"...), raw[m[6]:]...)
+	}
+
+	reader := bufio.NewReader(bytes.NewReader(raw))
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return Request{}, err
+	}
+	t.Method = req.Method
+
+	// Build the URL, from an absolute request target if the request was meant for a proxy
+	target := req.URL
+	if !target.IsAbs() {
+		target = &url.URL{Path: req.URL.Path, RawPath: req.URL.RawPath, RawQuery: req.URL.RawQuery}
+		target.Scheme = "https"
+		target.Host = req.Host
+	}
+	if scheme != "" {
+		target.Scheme = scheme
+	}
+	if host != "" {
+		target.Host = host
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return Request{}, fmt.Errorf("unsupported scheme %q", target.Scheme)
+	}
+	if target.Host == "" {
+		return Request{}, fmt.Errorf("no Host header, and no host given")
+	}
+	t.URL = target.String()
+
+	// Headers are kept in the order they are written in, as far as the parser allows
+	for name, values := range req.Header {
+		if rawSkippedHeaders[name] {
+			continue
+		}
+		for _, value := range values {
+			t.Headers = append(t.Headers, fmt.Sprintf("%s: %s", name, value))
+		}
+	}
+	sortHeaders(t.Headers, raw)
+
+	for _, header := range req.Header["Cookie"] {
+		for _, cookie := range strings.Split(header, ";") {
+			if cookie = strings.TrimSpace(cookie); cookie != "" {
+				t.Cookies = append(t.Cookies, cookie)
+			}
+		}
+	}
+
+	// Read the body, which runs to the end of the file
+	var body []byte
+	if len(req.TransferEncoding) > 0 {
+		body, err = ioutil.ReadAll(req.Body)
+	} else {
+		body, err = ioutil.ReadAll(reader)
+	}
+	if err != nil {
+		return Request{}, fmt.Errorf("could not read body: %v", err)
+	}
+	t.Body = string(body)
+
+	return t, nil
+}
+
+// Function sortHeaders orders headers by where their names first appear in the raw request, as the parser
+// does not keep their order.
+func sortHeaders(headers []string, raw []byte) {
+	lower := bytes.ToLower(raw)
+	position := func(header string) int {
+		name := header[:strings.Index(header, ":")]
+		if i := bytes.Index(lower, []byte("\n"+strings.ToLower(name)+":")); i >= 0 {
+			return i
+		}
+		return len(raw)
+	}
+	// The values of repeated headers stay in order
+	sort.SliceStable(headers, func(i, j int) bool { return position(headers[i]) < position(headers[j]) })
+}
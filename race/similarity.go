@@ -0,0 +1,77 @@
+package race
+
+import (
+	"strings"
+	"unicode"
+)
+
+// tokenBag counts the words and numbers of a response body, to measure how similar bodies are.
+type tokenBag struct {
+	counts map[string]int
+	size   int
+}
+
+// Function newTokenBag splits a text into tokens of letters and digits, and counts them.
+func newTokenBag(text string) *tokenBag {
+	bag := &tokenBag{counts: make(map[string]int)}
+	for _, token := range strings.FieldsFunc(text, func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	}) {
+		bag.counts[token]++
+		bag.size++
+	}
+	return bag
+}
+
+// Function similarity returns the weighted Jaccard similarity of two bags of tokens, from 0 (nothing in common) to 1 (the same tokens).
+func (a *tokenBag) similarity(b *tokenBag) float64 {
+	if a.size == 0 && b.size == 0 {
+		return 1
+	}
+	// Every token counts towards the union, and the tokens in both bags towards the intersection
+	shared := 0
+	for token, countA := range a.counts {
+		if countB := b.counts[token]; countB < countA {
+			shared += countB
+		} else {
+			shared += countA
+		}
+	}
+	return float64(shared) / float64(a.size+b.size-shared)
+}
+
+// Function closestResponse finds the unique response most similar to a normalized response, comparing the bodies of those
+// with the same status code and compared headers. Returns its index and similarity, and whether it reaches the
+// configured similarity threshold.
+func (r *Runner) closestResponse(uniqueResponses []UniqueResponseInfo, key responseKey) (closest int, similarity float64, ok bool) {
+	var tokens *tokenBag
+	for i := range uniqueResponses {
+		u := &uniqueResponses[i]
+		if u.key.head != key.head {
+			continue
+		}
+		if tokens == nil {
+			tokens = newTokenBag(key.body)
+		}
+		if u.tokens == nil {
+			u.tokens = newTokenBag(u.key.body)
+		}
+
+		// Bodies of very different sizes cannot be similar enough, so skip counting their shared tokens
+		smaller, larger := u.tokens.size, tokens.size
+		if smaller > larger {
+			smaller, larger = larger, smaller
+		}
+		if larger > 0 {
+			bound := float64(smaller) / float64(larger)
+			if bound < r.Config.Similarity || bound <= similarity {
+				continue
+			}
+		}
+
+		if s := u.tokens.similarity(tokens); s > similarity || !ok {
+			closest, similarity, ok = i, s, true
+		}
+	}
+	return closest, similarity, ok && similarity >= r.Config.Similarity
+}
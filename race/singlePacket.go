@@ -0,0 +1,661 @@
+package race
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	// Used to encode and decode HTTP/2 header blocks
+	"golang.org/x/net/http2/hpack"
+)
+
+// HTTP/2 frame types, flags and settings used by the single-packet engine (RFC 7540)
+const (
+	h2FrameData         = 0x0
+	h2FrameHeaders      = 0x1
+	h2FrameRSTStream    = 0x3
+	h2FrameSettings     = 0x4
+	h2FramePing         = 0x6
+	h2FrameGoAway       = 0x7
+	h2FrameWindowUpdate = 0x8
+	h2FrameContinuation = 0x9
+
+	h2FlagEndStream  = 0x1
+	h2FlagAck        = 0x1
+	h2FlagEndHeaders = 0x4
+	h2FlagPadded     = 0x8
+	h2FlagPriority   = 0x20
+
+	h2SettingEnablePush           = 0x2
+	h2SettingMaxConcurrentStreams = 0x3
+	h2SettingInitialWindowSize    = 0x4
+	h2SettingMaxFrameSize         = 0x5
+
+	h2Preface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+)
+
+// h2Conn is a single HTTP/2 connection, carrying a batch of requests as concurrent streams.
+type h2Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	dec    *hpack.Decoder
+
+	// Limits advertised by the server
+	maxStreams   int
+	maxFrameSize int
+	streamWindow int
+	connWindow   int
+
+	// Frames holding the final piece of every request, withheld until release
+	final bytes.Buffer
+	// Requests sent on this connection, by stream ID
+	streams map[uint32]*h2Stream
+}
+
+// h2Stream holds the state of one request/response exchange on an HTTP/2 connection.
+type h2Stream struct {
+	sent      Request
+	req       *http.Request
+	header    http.Header
+	status    int
+	body      bytes.Buffer
+	block     []byte // header block fragments, until END_HEADERS is received
+	endStream bool   // END_STREAM was set on a header block that is still being received
+	done      bool
+	err       error
+	timing    RequestTiming
+}
+
+// Function sendSinglePacket sends the copies of a request as streams multiplexed over HTTP/2 connections,
+// using the single-packet technique. Every stream is opened and its request written, except for the frame
+// carrying END_STREAM. Once the start channel is closed, the withheld frames of each connection are flushed
+// in one TCP write, so that the server receives all of the requests in the same packet.
+// Requests are spread over several connections if they do not fit within the server's limits on a single one.
+// Every copy must be sent to the same host, as they share the connections.
+// Redirects are never followed in this mode. The connections are closed if ctx is done.
+func (r *Runner) sendSinglePacket(ctx context.Context, copies []requestCopy, ready *sync.WaitGroup, start <-chan struct{}) (responses []ResponseInfo, errors []error) {
+	// Mark the request as ready exactly once, even if it fails before reaching the barrier
+	var once sync.Once
+	markReady := func() {
+		once.Do(ready.Done)
+	}
+	defer markReady()
+
+	t, tURL := copies[0].Request, copies[0].url
+	if tURL.Scheme != "https" {
+		return nil, []error{fmt.Errorf("HTTP/2 requires an https URL: %s", tURL.String())}
+	}
+	for _, c := range copies {
+		if c.url.Scheme != tURL.Scheme || c.url.Host != tURL.Host {
+			return nil, []error{fmt.Errorf("HTTP/2 requests share their connections, so must all be sent to %s, not %s", tURL.Host, c.url.Host)}
+		}
+	}
+
+	// Open connections and prime the streams until every copy of the request has been placed
+	var conns []*h2Conn
+	defer func() {
+		for _, c := range conns {
+			c.conn.Close()
+		}
+	}()
+	for placed := 0; placed < len(copies); {
+		c, err := r.openH2Conn(ctx, tURL, t.requestTimeout())
+		if err != nil {
+			return nil, []error{err}
+		}
+		conns = append(conns, c)
+		defer closeOnDone(ctx, c.conn)()
+
+		n, err := c.prime(copies[placed:], r.buildRequest)
+		if err != nil {
+			return nil, []error{err}
+		}
+		placed += n
+	}
+	markReady()
+	select {
+	case <-start:
+	case <-ctx.Done():
+		return nil, []error{ctx.Err()}
+	}
+
+	// Release the final frames of every connection together, and collect the responses
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for _, c := range conns {
+		wg.Add(1)
+		go func(c *h2Conn) {
+			defer wg.Done()
+			resps, errs := c.release(t.requestTimeout(), func(sent Request) {
+				r.emit(EventSent, sent, Event{})
+			})
+			mu.Lock()
+			responses = append(responses, resps...)
+			errors = append(errors, errs...)
+			mu.Unlock()
+		}(c)
+	}
+	wg.Wait()
+
+	return
+}
+
+// Function openH2Conn connects to the target and performs the HTTP/2 connection preface,
+// learning the limits advertised in the server's SETTINGS frame. Priming must complete within timeout.
+func (r *Runner) openH2Conn(ctx context.Context, tURL *url.URL, timeout time.Duration) (*h2Conn, error) {
+	conn, _, err := r.dialTarget(ctx, tURL, true)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	c := &h2Conn{
+		conn:         conn,
+		reader:       bufio.NewReader(conn),
+		dec:          hpack.NewDecoder(4096, nil),
+		maxStreams:   -1,
+		maxFrameSize: 16384,
+		streamWindow: 65535,
+		connWindow:   65535,
+		streams:      make(map[uint32]*h2Stream),
+	}
+
+	// Client preface: disable server push, as pushed responses are never used
+	var preface bytes.Buffer
+	preface.WriteString(h2Preface)
+	writeH2Frame(&preface, h2FrameSettings, 0, 0, h2Setting(h2SettingEnablePush, 0))
+	if _, err := conn.Write(preface.Bytes()); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error in writing HTTP/2 preface: %v", err)
+	}
+
+	// Wait for the server's SETTINGS frame, which must come first
+	typ, flags, _, payload, err := c.readFrame()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error in reading HTTP/2 settings: %v", err)
+	}
+	if typ != h2FrameSettings || flags&h2FlagAck != 0 {
+		conn.Close()
+		return nil, fmt.Errorf("server did not start with an HTTP/2 SETTINGS frame")
+	}
+	c.applySettings(payload)
+	var ack bytes.Buffer
+	writeH2Frame(&ack, h2FrameSettings, h2FlagAck, 0, nil)
+	if _, err := conn.Write(ack.Bytes()); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error in acknowledging HTTP/2 settings: %v", err)
+	}
+
+	// Pick up any window updates sent along with the settings, without blocking
+	for c.reader.Buffered() >= 9 {
+		header, _ := c.reader.Peek(9)
+		if c.reader.Buffered() < 9+int(uint32(header[0])<<16|uint32(header[1])<<8|uint32(header[2])) {
+			break
+		}
+		typ, flags, streamID, payload, err := c.readFrame()
+		if err != nil {
+			break
+		}
+		switch {
+		case typ == h2FrameWindowUpdate && streamID == 0 && len(payload) == 4:
+			c.connWindow += int(binary.BigEndian.Uint32(payload) & 0x7fffffff)
+		case typ == h2FrameSettings && flags&h2FlagAck == 0:
+			c.applySettings(payload)
+		}
+	}
+
+	return c, nil
+}
+
+// Function prime opens a stream on the connection for as many of the copies as the server's limits allow, in order,
+// writing everything except for the frames carrying END_STREAM. Each stream's request is formed by newRequest.
+// Returns the number of streams opened.
+func (c *h2Conn) prime(copies []requestCopy, newRequest func(Request, *url.URL) (*http.Request, error)) (int, error) {
+	// Every byte of the body counts against the flow-control windows, including the withheld final byte
+	n, window := 0, c.connWindow
+	for _, rc := range copies {
+		bodyLen := len(rc.Body)
+		if bodyLen > c.streamWindow {
+			return 0, fmt.Errorf("request body is larger than the server's HTTP/2 stream window (%d bytes)", c.streamWindow)
+		}
+		if (c.maxStreams >= 0 && n == c.maxStreams) || bodyLen > window {
+			break
+		}
+		window -= bodyLen
+		n++
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("server does not accept any HTTP/2 streams for this request")
+	}
+
+	var out bytes.Buffer
+	var block bytes.Buffer
+	enc := hpack.NewEncoder(&block)
+	for i, rc := range copies[:n] {
+		streamID := uint32(2*i + 1)
+		req, err := newRequest(rc.Request, rc.url)
+		if err != nil {
+			return 0, fmt.Errorf("error in forming request: %v", err)
+		}
+		c.streams[streamID] = &h2Stream{sent: rc.Request, req: req}
+
+		// Encode the header block, splitting it across CONTINUATION frames if needed
+		block.Reset()
+		for _, field := range h2HeaderFields(req, len(rc.Body)) {
+			enc.WriteField(field)
+		}
+		fragment := block.Bytes()
+		typ := byte(h2FrameHeaders)
+		for {
+			size := len(fragment)
+			if size > c.maxFrameSize {
+				size = c.maxFrameSize
+			}
+			var flags byte
+			if size == len(fragment) {
+				flags = h2FlagEndHeaders
+			}
+			writeH2Frame(&out, typ, flags, streamID, fragment[:size])
+			fragment = fragment[size:]
+			if len(fragment) == 0 {
+				break
+			}
+			typ = h2FrameContinuation
+		}
+
+		// Send all of the body except the final byte, which is withheld with END_STREAM
+		body := []byte(rc.Body)
+		var last []byte
+		if len(body) > 0 {
+			last = body[len(body)-1:]
+			body = body[:len(body)-1]
+		}
+		for len(body) > 0 {
+			size := len(body)
+			if size > c.maxFrameSize {
+				size = c.maxFrameSize
+			}
+			writeH2Frame(&out, h2FrameData, 0, streamID, body[:size])
+			body = body[size:]
+		}
+		writeH2Frame(&c.final, h2FrameData, h2FlagEndStream, streamID, last)
+	}
+
+	primed := time.Now()
+	if _, err := c.conn.Write(out.Bytes()); err != nil {
+		return 0, fmt.Errorf("error in writing HTTP/2 requests: %v", err)
+	}
+	for _, s := range c.streams {
+		s.timing.FirstByteSent = primed
+	}
+	return n, nil
+}
+
+// Function release flushes the withheld frames in a single write, then reads the responses
+// for every stream on the connection, within timeout. Function sent is called for every stream once written.
+func (c *h2Conn) release(timeout time.Duration, sent func(Request)) (responses []ResponseInfo, errors []error) {
+	start := time.Now()
+	c.conn.SetDeadline(start.Add(timeout))
+	if _, err := c.conn.Write(c.final.Bytes()); err != nil {
+		return nil, []error{fmt.Errorf("error in writing final HTTP/2 frames: %v", err)}
+	}
+	written := time.Now()
+	for _, s := range c.streams {
+		s.timing.Start = start
+		s.timing.LastByteSent = written
+		sent(s.sent)
+	}
+
+	remaining := c.pending()
+	for remaining > 0 {
+		typ, flags, streamID, payload, err := c.readFrame()
+		if err != nil {
+			c.fail(fmt.Errorf("error in reading HTTP/2 response: %v", err))
+			break
+		}
+
+		if streamID == 0 {
+			if err := c.handleConnFrame(typ, flags, payload); err != nil {
+				c.fail(err)
+				break
+			}
+			// Streams refused in a GOAWAY frame are finished
+			remaining = c.pending()
+			continue
+		}
+
+		s, ok := c.streams[streamID]
+		if !ok || s.done {
+			continue
+		}
+		if s.timing.FirstByteRecvd.IsZero() {
+			s.timing.FirstByteRecvd = time.Now()
+		}
+		if err := c.handleStreamFrame(s, streamID, typ, flags, payload); err != nil {
+			s.err = err
+			s.done = true
+		}
+		if s.done {
+			s.timing.Done = time.Now()
+			remaining--
+		}
+	}
+
+	// Build the responses in stream order
+	ids := make([]int, 0, len(c.streams))
+	for id := range c.streams {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		s := c.streams[uint32(id)]
+		if s.err != nil {
+			errors = append(errors, fmt.Errorf("Error in HTTP/2 stream %d: %v", id, s.err))
+			continue
+		}
+		responses = append(responses, ResponseInfo{Response: s.response(), Target: s.sent, Timing: s.timing})
+	}
+	return
+}
+
+// Function handleConnFrame processes a frame sent on the connection itself (stream 0).
+func (c *h2Conn) handleConnFrame(typ, flags byte, payload []byte) error {
+	var out bytes.Buffer
+	switch typ {
+	case h2FrameSettings:
+		if flags&h2FlagAck == 0 {
+			c.applySettings(payload)
+			writeH2Frame(&out, h2FrameSettings, h2FlagAck, 0, nil)
+		}
+	case h2FramePing:
+		if flags&h2FlagAck == 0 {
+			writeH2Frame(&out, h2FramePing, h2FlagAck, 0, payload)
+		}
+	case h2FrameGoAway:
+		if len(payload) < 8 {
+			return fmt.Errorf("malformed HTTP/2 GOAWAY frame")
+		}
+		lastID := binary.BigEndian.Uint32(payload) & 0x7fffffff
+		code := binary.BigEndian.Uint32(payload[4:])
+		// Streams the server never processed will not get a response, so they fail now instead of at the timeout
+		for id, s := range c.streams {
+			if id > lastID && !s.done {
+				s.err = fmt.Errorf("connection closed by server (GOAWAY, error code %d)", code)
+				s.done = true
+				s.timing.Done = time.Now()
+			}
+		}
+	}
+	if out.Len() > 0 {
+		if _, err := c.conn.Write(out.Bytes()); err != nil {
+			return fmt.Errorf("error in writing HTTP/2 frame: %v", err)
+		}
+	}
+	return nil
+}
+
+// Function handleStreamFrame processes a frame belonging to one of the connection's streams.
+func (c *h2Conn) handleStreamFrame(s *h2Stream, streamID uint32, typ, flags byte, payload []byte) error {
+	switch typ {
+	case h2FrameHeaders, h2FrameContinuation:
+		if typ == h2FrameHeaders {
+			var err error
+			if payload, err = h2StripPadding(flags, payload); err != nil {
+				return err
+			}
+			if flags&h2FlagPriority != 0 {
+				if len(payload) < 5 {
+					return fmt.Errorf("malformed HTTP/2 HEADERS frame")
+				}
+				payload = payload[5:]
+			}
+			s.endStream = flags&h2FlagEndStream != 0
+		}
+		s.block = append(s.block, payload...)
+		if flags&h2FlagEndHeaders == 0 {
+			return nil
+		}
+		fields, err := c.dec.DecodeFull(s.block)
+		s.block = nil
+		if err != nil {
+			return fmt.Errorf("error in decoding headers: %v", err)
+		}
+		if err := s.addHeaders(fields); err != nil {
+			return err
+		}
+		if s.endStream {
+			s.done = true
+		}
+
+	case h2FrameData:
+		// Return the received bytes to the flow-control windows, so that large bodies are not stalled
+		if len(payload) > 0 {
+			var out bytes.Buffer
+			writeH2Frame(&out, h2FrameWindowUpdate, 0, 0, h2WindowIncrement(len(payload)))
+			writeH2Frame(&out, h2FrameWindowUpdate, 0, streamID, h2WindowIncrement(len(payload)))
+			if _, err := c.conn.Write(out.Bytes()); err != nil {
+				return fmt.Errorf("error in writing HTTP/2 window update: %v", err)
+			}
+		}
+		data, err := h2StripPadding(flags, payload)
+		if err != nil {
+			return err
+		}
+		s.body.Write(data)
+		if flags&h2FlagEndStream != 0 {
+			s.done = true
+		}
+
+	case h2FrameRSTStream:
+		if len(payload) < 4 {
+			return fmt.Errorf("malformed HTTP/2 RST_STREAM frame")
+		}
+		return fmt.Errorf("stream reset by server (error code %d)", binary.BigEndian.Uint32(payload))
+	}
+
+	return nil
+}
+
+// Function addHeaders records a decoded header block. Informational (1xx) responses are skipped,
+// and header blocks received after the response headers are treated as trailers.
+func (s *h2Stream) addHeaders(fields []hpack.HeaderField) error {
+	if s.header == nil {
+		s.header = make(http.Header)
+	}
+	if s.status != 0 {
+		// Trailers
+		for _, f := range fields {
+			s.header.Add(http.CanonicalHeaderKey(f.Name), f.Value)
+		}
+		return nil
+	}
+
+	var status int
+	header := make(http.Header)
+	for _, f := range fields {
+		if f.Name == ":status" {
+			code, err := strconv.Atoi(f.Value)
+			if err != nil {
+				return fmt.Errorf("invalid :status %q", f.Value)
+			}
+			status = code
+			continue
+		}
+		if strings.HasPrefix(f.Name, ":") {
+			continue
+		}
+		header.Add(http.CanonicalHeaderKey(f.Name), f.Value)
+	}
+	if status == 0 {
+		return fmt.Errorf("response headers missing :status")
+	}
+	if status >= 100 && status < 200 {
+		return nil
+	}
+	s.status = status
+	s.header = header
+	return nil
+}
+
+// Function response converts a completed stream into an *http.Response.
+func (s *h2Stream) response() *http.Response {
+	contentLength := int64(-1)
+	if cl := s.header.Get("Content-Length"); cl != "" {
+		if n, err := strconv.ParseInt(cl, 10, 64); err == nil {
+			contentLength = n
+		}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", s.status, http.StatusText(s.status)),
+		StatusCode:    s.status,
+		Proto:         "HTTP/2.0",
+		ProtoMajor:    2,
+		Header:        s.header,
+		Body:          ioutil.NopCloser(bytes.NewReader(s.body.Bytes())),
+		ContentLength: contentLength,
+		Request:       s.req,
+	}
+}
+
+// Function pending returns the number of streams on the connection still waiting for their response.
+func (c *h2Conn) pending() int {
+	n := 0
+	for _, s := range c.streams {
+		if !s.done {
+			n++
+		}
+	}
+	return n
+}
+
+// Function fail marks every unfinished stream on the connection as failed.
+func (c *h2Conn) fail(err error) {
+	for _, s := range c.streams {
+		if !s.done {
+			if s.err == nil {
+				s.err = err
+			}
+			s.done = true
+		}
+	}
+}
+
+// Function applySettings records the limits from a SETTINGS frame sent by the server.
+func (c *h2Conn) applySettings(payload []byte) {
+	for i := 0; i+6 <= len(payload); i += 6 {
+		id := binary.BigEndian.Uint16(payload[i:])
+		val := int(binary.BigEndian.Uint32(payload[i+2:]))
+		switch id {
+		case h2SettingMaxConcurrentStreams:
+			c.maxStreams = val
+		case h2SettingInitialWindowSize:
+			c.streamWindow = val
+		case h2SettingMaxFrameSize:
+			c.maxFrameSize = val
+		}
+	}
+}
+
+// Function readFrame reads the next frame from the connection.
+func (c *h2Conn) readFrame() (typ, flags byte, streamID uint32, payload []byte, err error) {
+	var header [9]byte
+	if _, err = io.ReadFull(c.reader, header[:]); err != nil {
+		return
+	}
+	length := uint32(header[0])<<16 | uint32(header[1])<<8 | uint32(header[2])
+	typ = header[3]
+	flags = header[4]
+	streamID = binary.BigEndian.Uint32(header[5:]) & 0x7fffffff
+	payload = make([]byte, length)
+	_, err = io.ReadFull(c.reader, payload)
+	return
+}
+
+// Function writeH2Frame appends a single frame to buf.
+func writeH2Frame(buf *bytes.Buffer, typ, flags byte, streamID uint32, payload []byte) {
+	length := len(payload)
+	buf.Write([]byte{byte(length >> 16), byte(length >> 8), byte(length), typ, flags})
+	binary.Write(buf, binary.BigEndian, streamID&0x7fffffff)
+	buf.Write(payload)
+}
+
+// Function h2Setting encodes a single SETTINGS parameter.
+func h2Setting(id uint16, val uint32) []byte {
+	setting := make([]byte, 6)
+	binary.BigEndian.PutUint16(setting, id)
+	binary.BigEndian.PutUint32(setting[2:], val)
+	return setting
+}
+
+// Function h2WindowIncrement encodes the payload of a WINDOW_UPDATE frame.
+func h2WindowIncrement(n int) []byte {
+	increment := make([]byte, 4)
+	binary.BigEndian.PutUint32(increment, uint32(n))
+	return increment
+}
+
+// Function h2StripPadding removes the padding from the payload of a padded DATA or HEADERS frame.
+func h2StripPadding(flags byte, payload []byte) ([]byte, error) {
+	if flags&h2FlagPadded == 0 {
+		return payload, nil
+	}
+	if len(payload) == 0 || int(payload[0]) >= len(payload) {
+		return nil, fmt.Errorf("malformed padded HTTP/2 frame")
+	}
+	return payload[1 : len(payload)-int(payload[0])], nil
+}
+
+// Function h2HeaderFields converts a request into the list of HTTP/2 header fields,
+// dropping the connection-specific headers that HTTP/2 forbids.
+func h2HeaderFields(req *http.Request, bodyLen int) []hpack.HeaderField {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	fields := []hpack.HeaderField{
+		{Name: ":method", Value: req.Method},
+		{Name: ":scheme", Value: req.URL.Scheme},
+		{Name: ":authority", Value: host},
+		{Name: ":path", Value: req.URL.RequestURI()},
+	}
+
+	// Sort the header names, so that every request is encoded the same way
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	userAgent := false
+	for _, name := range names {
+		lower := strings.ToLower(name)
+		switch lower {
+		case "connection", "keep-alive", "proxy-connection", "transfer-encoding", "upgrade", "host", "content-length":
+			continue
+		case "user-agent":
+			userAgent = true
+		}
+		for _, value := range req.Header[name] {
+			fields = append(fields, hpack.HeaderField{Name: lower, Value: strings.TrimSpace(value)})
+		}
+	}
+	if !userAgent {
+		fields = append(fields, hpack.HeaderField{Name: "user-agent", Value: "Go-http-client/2.0"})
+	}
+	if bodyLen > 0 {
+		fields = append(fields, hpack.HeaderField{Name: "content-length", Value: strconv.Itoa(bodyLen)})
+	}
+	return fields
+}
@@ -0,0 +1,54 @@
+package race
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2/hpack"
+)
+
+func TestReleaseGoAway(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	c := &h2Conn{
+		conn:    client,
+		reader:  bufio.NewReader(client),
+		dec:     hpack.NewDecoder(4096, nil),
+		streams: make(map[uint32]*h2Stream),
+	}
+	for _, id := range []uint32{1, 3, 5} {
+		c.streams[id] = &h2Stream{}
+	}
+	writeH2Frame(&c.final, h2FramePing, 0, 0, make([]byte, 8))
+
+	// The server answers the first stream, then refuses the others, and leaves the connection open
+	go func() {
+		io.ReadFull(server, make([]byte, c.final.Len()))
+		var block, out bytes.Buffer
+		hpack.NewEncoder(&block).WriteField(hpack.HeaderField{Name: ":status", Value: "200"})
+		writeH2Frame(&out, h2FrameHeaders, h2FlagEndHeaders|h2FlagEndStream, 1, block.Bytes())
+		goAway := make([]byte, 8)
+		binary.BigEndian.PutUint32(goAway, 1)
+		writeH2Frame(&out, h2FrameGoAway, 0, 0, goAway)
+		server.Write(out.Bytes())
+	}()
+
+	started := time.Now()
+	responses, errors := c.release(5*time.Second, func(Request) {})
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("release took %v, waiting for streams refused by GOAWAY", elapsed)
+	}
+	if len(responses) != 1 || responses[0].Response.StatusCode != 200 {
+		t.Errorf("release returned responses %+v, want one 200 response", responses)
+	}
+	if len(errors) != 2 {
+		t.Errorf("release returned errors %v, want one for each refused stream", errors)
+	}
+}
@@ -0,0 +1,213 @@
+package race
+
+import (
+	"crypto/rand"
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// templateVariable matches a template variable, such as {{index}} or {{csv:email}}, and the argument given to it.
+// It also matches templateEscape, which stands for a literal "{{", so that a variable can be sent as it is written.
+var templateVariable = regexp.MustCompile(`\{\{\{\{|\{\{\s*([a-z_]+)(?::([^{}]*?))?\s*\}\}`)
+
+// templateEscape is written as "{{" once expanded. Every other match of templateVariable whose name is not a
+// template variable is left as it is, so that payloads for other template engines are sent unchanged.
+const templateEscape = "{{{{"
+
+// Template variables, expanded in the URL, body, headers and cookies of every copy of a request
+const (
+	templateIndex     = "index"      // Number of the copy, starting from 0
+	templateUUID      = "uuid"       // Random version 4 UUID
+	templateRandomInt = "random_int" // Random integer from 0 to 2147483647, or within the range given as "min:max"
+	templateWord      = "word"       // Line of the wordlist file for the copy
+	templateCSV       = "csv"        // Value of the column given, in the row of the CSV file for the copy
+)
+
+// templateVariables are the names of every template variable.
+var templateVariables = map[string]bool{
+	templateIndex:     true,
+	templateUUID:      true,
+	templateRandomInt: true,
+	templateWord:      true,
+	templateCSV:       true,
+}
+
+// requestTemplate holds the values that the template variables of a target draw from. Copies take the line of the
+// wordlist, and the row of the CSV file, with their own number, starting again from the first once all are used.
+type requestTemplate struct {
+	used    bool           // Whether the target has any template variables, as most do not
+	words   []string       // Lines of the wordlist file
+	columns map[string]int // Index of each column of the CSV file, by the name in its header row
+	rows    [][]string     // Rows of the CSV file, after the header row
+}
+
+// requestCopy is a single copy of a target to send, with its template variables expanded and its URL parsed.
+type requestCopy struct {
+	Request
+	url *url.URL
+}
+
+// Function compileTemplates reads the wordlist and CSV files of every target, and checks their template variables.
+func (r *Runner) compileTemplates() error {
+	r.templates = make([]*requestTemplate, len(r.Config.Requests))
+	for i, t := range r.Config.Requests {
+		tmpl := &requestTemplate{}
+		if t.Wordlist != "" {
+			raw, err := ioutil.ReadFile(t.Wordlist)
+			if err != nil {
+				return fmt.Errorf("Could not read wordlist for %s: %v", t.URL, err)
+			}
+			for _, line := range strings.Split(string(raw), "\n") {
+				if line = strings.TrimRight(line, "\r"); line != "" {
+					tmpl.words = append(tmpl.words, line)
+				}
+			}
+			if len(tmpl.words) == 0 {
+				return fmt.Errorf("Wordlist %s for %s is empty", t.Wordlist, t.URL)
+			}
+		}
+		if t.CSV != "" {
+			if err := tmpl.readCSV(t.CSV); err != nil {
+				return fmt.Errorf("Could not read CSV file for %s: %v", t.URL, err)
+			}
+		}
+
+		// Every variable is expanded once, so that mistakes are found before any request is sent
+		for _, s := range t.templated() {
+			for _, m := range templateVariable.FindAllStringSubmatch(s, -1) {
+				if m[0] != templateEscape && !templateVariables[m[1]] {
+					continue
+				}
+				tmpl.used = true
+				if _, err := tmpl.value(m[0], m[1], m[2], 0); err != nil {
+					return fmt.Errorf("Invalid template variable %s for %s: %v", m[0], t.URL, err)
+				}
+			}
+		}
+		r.templates[i] = tmpl
+	}
+	return nil
+}
+
+// Function readCSV reads the rows of a CSV file, naming its columns after the header row.
+func (tmpl *requestTemplate) readCSV(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(records) < 2 {
+		return fmt.Errorf("%s needs a header row, and at least one row of values", path)
+	}
+	tmpl.columns = make(map[string]int)
+	for i, name := range records[0] {
+		tmpl.columns[strings.TrimSpace(name)] = i
+	}
+	tmpl.rows = records[1:]
+	return nil
+}
+
+// Function templated returns the fields of the target that template variables are expanded in.
+func (t Request) templated() []string {
+	fields := append([]string{t.URL, t.Body}, t.Headers...)
+	return append(fields, t.Cookies...)
+}
+
+// Function expand returns the copy of the target with the given number, with its template variables expanded.
+func (tmpl *requestTemplate) expand(t Request, index int) (Request, error) {
+	if !tmpl.used {
+		return t, nil
+	}
+	var err error
+	replace := func(s string) string {
+		return templateVariable.ReplaceAllStringFunc(s, func(variable string) string {
+			m := templateVariable.FindStringSubmatch(variable)
+			value, vErr := tmpl.value(m[0], m[1], m[2], index)
+			if vErr != nil && err == nil {
+				err = vErr
+			}
+			return value
+		})
+	}
+	replaceAll := func(values []string) []string {
+		if values == nil {
+			return nil
+		}
+		expanded := make([]string, len(values))
+		for i, value := range values {
+			expanded[i] = replace(value)
+		}
+		return expanded
+	}
+
+	t.URL = replace(t.URL)
+	t.Body = replace(t.Body)
+	t.Headers = replaceAll(t.Headers)
+	t.Cookies = replaceAll(t.Cookies)
+	return t, err
+}
+
+// Function value returns the value of a template variable for the copy with the given number. Anything else that
+// templateVariable matched is returned as it is, except for templateEscape.
+func (tmpl *requestTemplate) value(variable, name, arg string, index int) (string, error) {
+	if variable == templateEscape {
+		return "{{", nil
+	}
+	switch name {
+	case templateIndex:
+		return strconv.Itoa(index), nil
+	case templateUUID:
+		var b [16]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			return "", err
+		}
+		b[6] = b[6]&0x0f | 0x40 // Version 4
+		b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+	case templateRandomInt:
+		min, max := int64(0), int64(math.MaxInt32)
+		if arg != "" {
+			bounds := strings.Split(arg, ":")
+			var minErr, maxErr error
+			if len(bounds) == 2 {
+				min, minErr = strconv.ParseInt(strings.TrimSpace(bounds[0]), 10, 64)
+				max, maxErr = strconv.ParseInt(strings.TrimSpace(bounds[1]), 10, 64)
+			}
+			if len(bounds) != 2 || minErr != nil || maxErr != nil || max < min || max-min < 0 {
+				return "", fmt.Errorf("expected a range such as {{%s:1:100}}", templateRandomInt)
+			}
+		}
+		n, err := rand.Int(rand.Reader, new(big.Int).Add(big.NewInt(max-min), big.NewInt(1)))
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatInt(n.Int64()+min, 10), nil
+	case templateWord:
+		if len(tmpl.words) == 0 {
+			return "", fmt.Errorf("no wordlist is set")
+		}
+		return tmpl.words[index%len(tmpl.words)], nil
+	case templateCSV:
+		if tmpl.rows == nil {
+			return "", fmt.Errorf("no CSV file is set")
+		}
+		column, ok := tmpl.columns[strings.TrimSpace(arg)]
+		if !ok {
+			return "", fmt.Errorf("the CSV file has no column %q", arg)
+		}
+		return tmpl.rows[index%len(tmpl.rows)][column], nil
+	}
+	return variable, nil
+}
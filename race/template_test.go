@@ -0,0 +1,101 @@
+package race
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "race-template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	wordlist := filepath.Join(dir, "words.txt")
+	sessions := filepath.Join(dir, "sessions.csv")
+	ioutil.WriteFile(wordlist, []byte("alice\r\nbob\n\n"), 0644)
+	ioutil.WriteFile(sessions, []byte("user, token\nalice,t1\nbob,t2\ncarol,t3\n"), 0644)
+
+	tests := []struct {
+		target Request
+		index  int
+		want   Request
+	}{
+		{
+			target: Request{URL: "http://h/{{index}}", Body: "n={{ index }}"},
+			index:  4,
+			want:   Request{URL: "http://h/4", Body: "n=4"},
+		},
+		{
+			target: Request{URL: "http://h/", Body: "{{word}}", Wordlist: wordlist},
+			index:  3,
+			want:   Request{URL: "http://h/", Body: "bob", Wordlist: wordlist},
+		},
+		{
+			target: Request{URL: "http://h/", Headers: []string{"X-Token: {{csv:token}}"}, Cookies: []string{"user={{csv:user}}"}, CSV: sessions},
+			index:  4,
+			want:   Request{URL: "http://h/", Headers: []string{"X-Token: t2"}, Cookies: []string{"user=bob"}, CSV: sessions},
+		},
+		{
+			target: Request{URL: "http://h/", Body: "{{7*7}} {{foo}} {{ config.items }} {{{{index}}"},
+			index:  1,
+			want:   Request{URL: "http://h/", Body: "{{7*7}} {{foo}} {{ config.items }} {{index}}"},
+		},
+		{
+			target: Request{URL: "http://h/", Body: "{{random_int:5:5}}"},
+			index:  0,
+			want:   Request{URL: "http://h/", Body: "5"},
+		},
+	}
+	for _, test := range tests {
+		r := NewRunner(Configuration{Requests: []Request{test.target}})
+		if err := r.compileTemplates(); err != nil {
+			t.Errorf("compileTemplates(%+v) returned error: %v", test.target, err)
+			continue
+		}
+		expanded, err := r.templates[0].expand(r.Config.Requests[0], test.index)
+		if err != nil {
+			t.Errorf("expand(%+v, %d) returned error: %v", test.target, test.index, err)
+			continue
+		}
+		if !reflect.DeepEqual(expanded, test.want) {
+			t.Errorf("expand(%+v, %d) = %+v, want %+v", test.target, test.index, expanded, test.want)
+		}
+	}
+
+	// Values drawn at random only need to be well formed, and within range
+	r := NewRunner(Configuration{Requests: []Request{{URL: "http://h/", Body: "{{uuid}} {{random_int:10:20}}"}}})
+	if err := r.compileTemplates(); err != nil {
+		t.Fatal(err)
+	}
+	expanded, err := r.templates[0].expand(r.Config.Requests[0], 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12} (\d+)$`).FindStringSubmatch(expanded.Body)
+	if m == nil {
+		t.Fatalf("expand returned %q, want a UUID and a number", expanded.Body)
+	}
+	if n, _ := strconv.Atoi(m[1]); n < 10 || n > 20 {
+		t.Errorf("{{random_int:10:20}} expanded to %d", n)
+	}
+
+	// Mistakes are found before any request is sent
+	for _, target := range []Request{
+		{URL: "http://h/{{word}}"},
+		{URL: "http://h/{{csv:user}}"},
+		{URL: "http://h/{{csv:email}}", CSV: sessions},
+		{URL: "http://h/{{random_int:9:1}}"},
+		{URL: "http://h/", Wordlist: filepath.Join(dir, "missing.txt")},
+	} {
+		r := NewRunner(Configuration{Requests: []Request{target}})
+		if err := r.compileTemplates(); err == nil {
+			t.Errorf("compileTemplates(%+v) returned no error", target)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package race
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RequestTiming records when each phase of a single request took place.
+type RequestTiming struct {
+	Start          time.Time // Request released from the start gate
+	FirstByteSent  time.Time // First byte of the request written (may precede Start, when requests are primed)
+	LastByteSent   time.Time // Last byte of the request written
+	FirstByteRecvd time.Time // First byte of the response received
+	Done           time.Time // Response body fully read
+}
+
+// TimingStats holds the minimum, median and maximum of a set of durations.
+type TimingStats struct {
+	Min    time.Duration
+	Median time.Duration
+	Max    time.Duration
+}
+
+// TimingSummary summarizes the timings of all the requests behind a unique response.
+// Offsets are measured from the moment the first request of the whole run was released.
+type TimingSummary struct {
+	StartOffset    TimingStats // When the request was released
+	LastByteOffset TimingStats // When the last byte of the request was written, i.e. when the server could act on it
+	FirstByte      TimingStats // Time from the last byte written, to the first byte of the response
+	Total          TimingStats // Time from release to the response being fully read
+}
+
+// Function withTimingTrace attaches an httptrace.ClientTrace to the request, recording its timings into timing.
+// The returned function must be called once the response body has been read.
+func withTimingTrace(req *http.Request, timing *RequestTiming) (*http.Request, func()) {
+	// The trace hooks may be called from the transport's goroutines
+	var mutex sync.Mutex
+	record := func(t *time.Time) {
+		mutex.Lock()
+		if t.IsZero() {
+			*t = time.Now()
+		}
+		mutex.Unlock()
+	}
+	trace := &httptrace.ClientTrace{
+		WroteHeaderField: func(key string, value []string) {
+			record(&timing.FirstByteSent)
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			record(&timing.LastByteSent)
+		},
+		GotFirstResponseByte: func() {
+			record(&timing.FirstByteRecvd)
+		},
+	}
+	done := func() {
+		mutex.Lock()
+		timing.Done = time.Now()
+		mutex.Unlock()
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace)), done
+}
+
+// Function summarizeTimings computes the timing statistics for a set of requests, relative to base.
+// Phases that were not recorded for a request are left out of its statistics.
+func summarizeTimings(timings []RequestTiming, base time.Time) TimingSummary {
+	var start, lastByte, firstByte, total []time.Duration
+	for _, t := range timings {
+		if t.Start.IsZero() {
+			continue
+		}
+		start = append(start, t.Start.Sub(base))
+		if !t.LastByteSent.IsZero() {
+			lastByte = append(lastByte, t.LastByteSent.Sub(base))
+			if !t.FirstByteRecvd.IsZero() {
+				firstByte = append(firstByte, t.FirstByteRecvd.Sub(t.LastByteSent))
+			}
+		}
+		if !t.Done.IsZero() {
+			total = append(total, t.Done.Sub(t.Start))
+		}
+	}
+	return TimingSummary{
+		StartOffset:    newTimingStats(start),
+		LastByteOffset: newTimingStats(lastByte),
+		FirstByte:      newTimingStats(firstByte),
+		Total:          newTimingStats(total),
+	}
+}
+
+// Function newTimingStats computes the minimum, median and maximum of a set of durations.
+func newTimingStats(durations []time.Duration) TimingStats {
+	if len(durations) == 0 {
+		return TimingStats{}
+	}
+	sorted := make([]time.Duration, len(durations))
+	copy(sorted, durations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	median := sorted[len(sorted)/2]
+	if len(sorted)%2 == 0 {
+		median = (sorted[len(sorted)/2-1] + median) / 2
+	}
+	return TimingStats{Min: sorted[0], Median: median, Max: sorted[len(sorted)-1]}
+}
+
+// String formats the statistics as min/median/max.
+func (s TimingStats) String() string {
+	return fmt.Sprintf("%v / %v / %v", s.Min, s.Median, s.Max)
+}
+
+// RenderTimeline draws an ASCII timeline of every request behind the unique responses, one row per request,
+// with time running from the first request released to the last response read. Each row is labelled with the
+// number of its unique response. Runs with more than maxRows requests are sampled evenly, and at least one row
+// and one column are drawn.
+// Legend: '>' sending the request, '.' waiting for the response, '=' receiving the response.
+func RenderTimeline(uniqueResponses []UniqueResponseInfo, width, maxRows int) string {
+	type row struct {
+		group  int
+		timing RequestTiming
+	}
+	var rows []row
+	var base, end time.Time
+	for i, data := range uniqueResponses {
+		for _, t := range data.timings {
+			if t.Start.IsZero() || t.Done.IsZero() {
+				continue
+			}
+			rows = append(rows, row{group: i + 1, timing: t})
+			if base.IsZero() || t.Start.Before(base) {
+				base = t.Start
+			}
+			if t.Done.After(end) {
+				end = t.Done
+			}
+		}
+	}
+	if len(rows) == 0 {
+		return ""
+	}
+	if width < 1 {
+		width = 1
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].timing.Start.Before(rows[j].timing.Start) })
+
+	// Sample the rows evenly, always keeping the first and last request released, or only the first for a single row
+	switch {
+	case len(rows) <= maxRows:
+	case maxRows <= 1:
+		rows = rows[:1]
+	default:
+		sampled := make([]row, maxRows)
+		for i := range sampled {
+			sampled[i] = rows[i*(len(rows)-1)/(maxRows-1)]
+		}
+		rows = sampled
+	}
+
+	span := end.Sub(base)
+	if span <= 0 {
+		span = 1
+	}
+	column := func(t time.Time) int {
+		if t.Before(base) {
+			return 0
+		}
+		c := int(int64(t.Sub(base)) * int64(width-1) / int64(span))
+		if c >= width {
+			c = width - 1
+		}
+		return c
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "%6s |%s| %v\n", "", strings.Repeat("-", width), span)
+	for _, r := range rows {
+		t := r.timing
+		// Fill in missing phases, so that each row is drawn from release to completion
+		lastByte, firstByte := t.LastByteSent, t.FirstByteRecvd
+		if lastByte.IsZero() || lastByte.Before(t.Start) {
+			lastByte = t.Start
+		}
+		if firstByte.IsZero() || firstByte.Before(lastByte) {
+			firstByte = lastByte
+		}
+
+		line := []byte(strings.Repeat(" ", width))
+		for c := column(t.Start); c <= column(t.Done); c++ {
+			line[c] = '='
+		}
+		for c := column(t.Start); c < column(firstByte); c++ {
+			line[c] = '.'
+		}
+		for c := column(t.Start); c <= column(lastByte); c++ {
+			line[c] = '>'
+		}
+		fmt.Fprintf(&out, "%6s |%s|\n", fmt.Sprintf("#%d", r.group), line)
+	}
+	return out.String()
+}
+
+// HistogramBucket counts the requests whose last byte was written within a slice of time.
+type HistogramBucket struct {
+	Start  time.Duration // Offset from the first request released
+	End    time.Duration
+	Counts []int // Requests in the bucket for each unique response, in order
+	Total  int
+}
+
+// TimingHistogram sorts every request behind the unique responses into the given number of buckets, by when its
+// last byte was written, i.e. when the server could act on it. Offsets are measured from the first request released.
+// Requests whose last byte was not recorded are placed by their release instead.
+func TimingHistogram(uniqueResponses []UniqueResponseInfo, buckets int) []HistogramBucket {
+	if buckets < 1 {
+		return nil
+	}
+	type point struct {
+		group int
+		at    time.Time
+	}
+	var points []point
+	var base, end time.Time
+	for i, data := range uniqueResponses {
+		for _, t := range data.timings {
+			if t.Start.IsZero() {
+				continue
+			}
+			at := t.LastByteSent
+			if at.IsZero() {
+				at = t.Start
+			}
+			points = append(points, point{group: i, at: at})
+			if base.IsZero() || t.Start.Before(base) {
+				base = t.Start
+			}
+			if at.After(end) {
+				end = at
+			}
+		}
+	}
+	if len(points) == 0 {
+		return nil
+	}
+
+	span := end.Sub(base)
+	if span <= 0 {
+		span = 1
+	}
+	histogram := make([]HistogramBucket, buckets)
+	for i := range histogram {
+		histogram[i].Start = span * time.Duration(i) / time.Duration(buckets)
+		histogram[i].End = span * time.Duration(i+1) / time.Duration(buckets)
+		histogram[i].Counts = make([]int, len(uniqueResponses))
+	}
+	for _, p := range points {
+		offset := p.at.Sub(base)
+		if offset < 0 {
+			// Requests primed before the first release
+			offset = 0
+		}
+		b := int(int64(offset) * int64(buckets) / int64(span))
+		if b >= buckets {
+			b = buckets - 1
+		}
+		histogram[b].Counts[p.group]++
+		histogram[b].Total++
+	}
+	return histogram
+}
@@ -0,0 +1,55 @@
+package race
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderTimeline(t *testing.T) {
+	base := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	timing := func(start, done int) RequestTiming {
+		return RequestTiming{
+			Start: base.Add(time.Duration(start) * time.Millisecond),
+			Done:  base.Add(time.Duration(done) * time.Millisecond),
+		}
+	}
+	responses := []UniqueResponseInfo{
+		{timings: []RequestTiming{timing(0, 10), timing(2, 6), timing(4, 8)}},
+		{timings: []RequestTiming{timing(6, 10), {}}},
+	}
+
+	tests := []struct {
+		maxRows int
+		rows    []string // Labels of the rows drawn
+	}{
+		{10, []string{"#1", "#1", "#1", "#2"}},
+		{4, []string{"#1", "#1", "#1", "#2"}},
+		{2, []string{"#1", "#2"}},
+		{1, []string{"#1"}},
+		{0, []string{"#1"}},
+	}
+	for _, test := range tests {
+		lines := strings.Split(strings.TrimSuffix(RenderTimeline(responses, 10, test.maxRows), "\n"), "\n")
+		if !strings.HasSuffix(lines[0], "| 10ms") {
+			t.Errorf("RenderTimeline with %d rows has header %q", test.maxRows, lines[0])
+		}
+		var rows []string
+		for _, line := range lines[1:] {
+			rows = append(rows, strings.TrimSpace(strings.SplitN(line, "|", 2)[0]))
+		}
+		if strings.Join(rows, " ") != strings.Join(test.rows, " ") {
+			t.Errorf("RenderTimeline with %d rows drew %q, want %q", test.maxRows, rows, test.rows)
+		}
+	}
+
+	for _, width := range []int{0, -5} {
+		if timeline, want := RenderTimeline(responses, width, 1), "       |-| 10ms\n    #1 |>|\n"; timeline != want {
+			t.Errorf("RenderTimeline %d columns wide = %q, want %q", width, timeline, want)
+		}
+	}
+
+	if timeline := RenderTimeline(nil, 10, 1); timeline != "" {
+		t.Errorf("RenderTimeline without requests = %q, want nothing", timeline)
+	}
+}
@@ -0,0 +1,190 @@
+package race
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// SuccessMatcher decides whether a response to a request means that the request succeeded, such as a coupon being redeemed.
+// A response is a success if it meets every condition that is set.
+type SuccessMatcher struct {
+	Status      []int  `json:"status,omitempty"`       // Any of these status codes
+	Body        string `json:"body,omitempty"`         // Regular expression matching the body
+	Header      string `json:"header,omitempty"`       // "Name" for a header to be present, or "Name: regular expression" to match its value
+	JSONPointer string `json:"json_pointer,omitempty"` // JSON pointer that must exist in the body
+	JSONValue   string `json:"json_value,omitempty"`   // Value expected at JSONPointer: strings as they are, other values as JSON (true, 42, null)
+	Max         *int   `json:"max,omitempty"`          // Most successes expected. More successes than this mean that the race condition is exploitable.
+}
+
+// Verdict states whether a race condition was found, based on the success matchers of the requests.
+// It is inconclusive if no race condition was found, but a success matcher has no most successes expected to judge by,
+// or fewer than two responses were received, as no race could be observed.
+type Verdict struct {
+	Vulnerable   bool
+	Inconclusive bool
+	Requests     []SuccessCount // Successes observed for each request with a success matcher
+}
+
+// SuccessCount is the number of successful responses observed for a request.
+type SuccessCount struct {
+	Request   int // Index of the request in the configuration
+	Method    string
+	URL       string
+	Successes int
+	Max       *int // Most successes expected, if set
+	Exceeded  bool // More successes were observed than expected
+}
+
+// Function String returns the verdict as a headline.
+func (v *Verdict) String() string {
+	switch {
+	case v.Vulnerable:
+		return "VULNERABLE"
+	case v.Inconclusive:
+		return "INCONCLUSIVE"
+	}
+	return "NOT VULNERABLE"
+}
+
+// Function compileSuccess checks the success matchers of every target, and compiles their patterns.
+func (r *Runner) compileSuccess() error {
+	for _, t := range r.Config.Requests {
+		m := t.Success
+		if m == nil {
+			continue
+		}
+		patterns := []string{m.Body}
+		if _, value := splitHeaderMatcher(m.Header); value != "" {
+			patterns = append(patterns, value)
+		}
+		for _, pattern := range patterns {
+			if pattern == "" {
+				continue
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("Invalid success pattern %q for %s: %v", pattern, t.URL, err)
+			}
+			r.patterns[pattern] = re
+		}
+		if _, err := parseJSONPointer(m.JSONPointer); err != nil {
+			return fmt.Errorf("Invalid JSON pointer %q for %s: %v", m.JSONPointer, t.URL, err)
+		}
+		if m.JSONValue != "" && m.JSONPointer == "" {
+			return fmt.Errorf("A JSON value to match for %s requires a JSON pointer", t.URL)
+		}
+		if m.Max != nil && *m.Max < 0 {
+			return fmt.Errorf("The most successes expected for %s cannot be negative", t.URL)
+		}
+	}
+	return nil
+}
+
+// Function isSuccess reports whether a response to the target meets its success matcher.
+func (r *Runner) isSuccess(t Request, data UniqueResponseData) bool {
+	m := t.Success
+	if m == nil {
+		return false
+	}
+
+	if len(m.Status) > 0 {
+		found := false
+		for _, status := range m.Status {
+			if status == data.StatusCode {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if m.Body != "" && !r.patterns[m.Body].MatchString(data.Body) {
+		return false
+	}
+
+	if m.Header != "" {
+		name, value := splitHeaderMatcher(m.Header)
+		values, ok := data.Headers[http.CanonicalHeaderKey(name)]
+		if !ok {
+			return false
+		}
+		if value != "" && !r.patterns[value].MatchString(strings.Join(values, ", ")) {
+			return false
+		}
+	}
+
+	if m.JSONPointer != "" {
+		v, err := decodeJSON(data.Body)
+		if err != nil {
+			return false
+		}
+		tokens, _ := parseJSONPointer(m.JSONPointer)
+		found, ok := selectJSONPointer(v, tokens)
+		if !ok {
+			return false
+		}
+		if m.JSONValue != "" {
+			text, isString := found.(string)
+			if !isString {
+				b, err := encodeJSON(found)
+				if err != nil {
+					return false
+				}
+				text = string(b)
+			}
+			if text != m.JSONValue {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// Function judge counts the successes of every target with a success matcher, and decides whether the race condition
+// was exploited, from the successes of each target and the number of responses received in all.
+// Returns nil if no target has a success matcher.
+func (r *Runner) judge(successes []int, received int) *Verdict {
+	var verdict *Verdict
+	for i, t := range r.Config.Requests {
+		if t.Success == nil {
+			continue
+		}
+		if verdict == nil {
+			verdict = &Verdict{}
+		}
+		count := SuccessCount{
+			Request:   i,
+			Method:    t.Method,
+			URL:       t.URL,
+			Successes: successes[i],
+			Max:       t.Success.Max,
+		}
+		switch {
+		case count.Max == nil:
+			verdict.Inconclusive = true
+		case count.Successes > *count.Max:
+			count.Exceeded = true
+			verdict.Vulnerable = true
+		}
+		verdict.Requests = append(verdict.Requests, count)
+	}
+	if verdict != nil {
+		verdict.Inconclusive = !verdict.Vulnerable && (verdict.Inconclusive || received < 2)
+	}
+	return verdict
+}
+
+// Function splitHeaderMatcher splits a header matcher into the header name and the pattern for its value, if any.
+func splitHeaderMatcher(header string) (name, value string) {
+	split := strings.SplitN(header, ":", 2)
+	name = strings.TrimSpace(split[0])
+	if len(split) == 2 {
+		value = strings.TrimSpace(split[1])
+	}
+	return name, value
+}
@@ -0,0 +1,92 @@
+package race
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestJudge(t *testing.T) {
+	one := 1
+	tests := []struct {
+		name      string
+		matchers  []*SuccessMatcher
+		successes []int
+		received  int
+		verdict   string
+	}{
+		{"no matchers", []*SuccessMatcher{nil}, []int{3}, 10, ""},
+		{"within max", []*SuccessMatcher{{Max: &one}}, []int{1}, 10, "NOT VULNERABLE"},
+		{"max exceeded", []*SuccessMatcher{{Max: &one}}, []int{2}, 10, "VULNERABLE"},
+		{"no max", []*SuccessMatcher{{}}, []int{0}, 10, "INCONCLUSIVE"},
+		{"no max on another request", []*SuccessMatcher{{Max: &one}, {}}, []int{1, 5}, 10, "INCONCLUSIVE"},
+		{"max exceeded on another request", []*SuccessMatcher{{}, {Max: &one}}, []int{0, 2}, 10, "VULNERABLE"},
+		{"request without matcher", []*SuccessMatcher{nil, {Max: &one}}, []int{9, 0}, 10, "NOT VULNERABLE"},
+		{"no responses", []*SuccessMatcher{{Max: &one}}, []int{0}, 0, "INCONCLUSIVE"},
+		{"one response", []*SuccessMatcher{{Max: &one}}, []int{1}, 1, "INCONCLUSIVE"},
+		{"two responses", []*SuccessMatcher{{Max: &one}}, []int{1}, 2, "NOT VULNERABLE"},
+		{"max exceeded by one response", []*SuccessMatcher{{Max: new(int)}}, []int{1}, 1, "VULNERABLE"},
+	}
+	for _, test := range tests {
+		var config Configuration
+		for _, m := range test.matchers {
+			config.Requests = append(config.Requests, Request{Method: "POST", URL: "http://h/", Success: m})
+		}
+		verdict := NewRunner(config).judge(test.successes, test.received)
+		if verdict == nil {
+			if test.verdict != "" {
+				t.Errorf("%s: no verdict, want %s", test.name, test.verdict)
+			}
+			continue
+		}
+		if verdict.String() != test.verdict {
+			t.Errorf("%s: verdict %s, want %q", test.name, verdict, test.verdict)
+		}
+		for _, count := range verdict.Requests {
+			if count.Successes != test.successes[count.Request] {
+				t.Errorf("%s: request %d has %d successes, want %d", test.name, count.Request, count.Successes, test.successes[count.Request])
+			}
+		}
+	}
+}
+
+func TestIsSuccess(t *testing.T) {
+	data := UniqueResponseData{
+		StatusCode: 200,
+		Headers:    http.Header{"Set-Cookie": {"receipt=42"}},
+		Body:       `{"success": true, "id": 7, "status": "paid"}`,
+	}
+	tests := []struct {
+		matcher SuccessMatcher
+		success bool
+	}{
+		{SuccessMatcher{}, true},
+		{SuccessMatcher{Status: []int{201, 200}}, true},
+		{SuccessMatcher{Status: []int{201}}, false},
+		{SuccessMatcher{Body: `"status": "(paid|done)"`}, true},
+		{SuccessMatcher{Body: `refused`}, false},
+		{SuccessMatcher{Header: "set-cookie"}, true},
+		{SuccessMatcher{Header: "Set-Cookie: receipt=\\d+"}, true},
+		{SuccessMatcher{Header: "Set-Cookie: session="}, false},
+		{SuccessMatcher{Header: "Location"}, false},
+		{SuccessMatcher{JSONPointer: "/success", JSONValue: "true"}, true},
+		{SuccessMatcher{JSONPointer: "/id", JSONValue: "7"}, true},
+		{SuccessMatcher{JSONPointer: "/status", JSONValue: "paid"}, true},
+		{SuccessMatcher{JSONPointer: "/status", JSONValue: `"paid"`}, false},
+		{SuccessMatcher{JSONPointer: "/missing"}, false},
+		{SuccessMatcher{Status: []int{200}, Body: "refused"}, false},
+	}
+	for _, test := range tests {
+		matcher := test.matcher
+		r := NewRunner(Configuration{Requests: []Request{{URL: "http://h/", Success: &matcher}}})
+		if err := r.compileNormalization(); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.compileSuccess(); err != nil {
+			t.Errorf("compileSuccess(%+v) returned error: %v", test.matcher, err)
+			continue
+		}
+		if success := r.isSuccess(r.Config.Requests[0], data); success != test.success {
+			t.Errorf("isSuccess(%+v) = %v, want %v", test.matcher, success, test.success)
+		}
+	}
+}
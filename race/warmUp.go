@@ -0,0 +1,89 @@
+package race
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Function newWarmClient creates the HTTP client for a single request, with its first connection already
+// open (DNS resolved, TCP connected and TLS negotiated), so that no request gets a head start over the others.
+// The client:
+// Ignores TLS errors
+// Ignores redirects (more accurate output), depending on user flag
+// Implements a connection timeout, for slow clients & servers (especially important with race conditions on the server)
+// The warm connection is closed if ctx is done. Function closeClient must be called once the request is done,
+// to close the connections of the client.
+func (r *Runner) newWarmClient(ctx context.Context, t Request, tURL *url.URL) (client *http.Client, closeClient func(), err error) {
+	// Open the connection the first request will use. Plain HTTP requests that go through a proxy
+	// are connected to the proxy, while everything else is connected (or tunnelled) to the target.
+	conn, _, err := r.dialTarget(ctx, tURL, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	stopClosing := closeOnDone(ctx, conn)
+
+	// Hand the warm connection to the transport the first time it dials. Any later connections
+	// (for redirects, for instance) are dialed as usual.
+	var warmOnce sync.Once
+	takeWarm := func() (c net.Conn) {
+		warmOnce.Do(func() {
+			c = conn
+		})
+		return
+	}
+	dialer := net.Dialer{Timeout: 30 * time.Second}
+
+	transport := &http.Transport{
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
+		},
+		// HTTPS requests are tunnelled through the proxy by DialTLS
+		Proxy: func(req *http.Request) (*url.URL, error) {
+			if r.Config.Proxy == "" || req.URL.Scheme == "https" {
+				return nil, nil
+			}
+			return url.Parse(r.Config.Proxy)
+		},
+		Dial: func(network, addr string) (net.Conn, error) {
+			if c := takeWarm(); c != nil {
+				return c, nil
+			}
+			return dialer.DialContext(ctx, network, addr)
+		},
+		DialTLS: func(network, addr string) (net.Conn, error) {
+			if c := takeWarm(); c != nil {
+				return c, nil
+			}
+			c, _, err := r.dialTarget(ctx, &url.URL{Scheme: "https", Host: addr}, false)
+			return c, err
+		},
+	}
+
+	client = &http.Client{
+		Jar:       t.CookieJar,
+		Transport: transport,
+		Timeout:   t.requestTimeout(),
+	}
+	if !t.Redirects {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			// Craft the custom error
+			redirectError := RedirectError{req}
+			return &redirectError
+		}
+	}
+
+	closeClient = func() {
+		stopClosing()
+		// The warm connection is left open if the request was never sent
+		if c := takeWarm(); c != nil {
+			c.Close()
+		}
+		transport.CloseIdleConnections()
+	}
+	return client, closeClient, nil
+}
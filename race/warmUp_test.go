@@ -0,0 +1,72 @@
+package race
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSendStandardClosesConnections(t *testing.T) {
+	var mu sync.Mutex
+	open := 0
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch state {
+		case http.StateNew:
+			open++
+		case http.StateClosed, http.StateHijacked:
+			open--
+		}
+	}
+	server.Start()
+	defer server.Close()
+	waitClosed := func(name string) {
+		for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			mu.Lock()
+			n := open
+			mu.Unlock()
+			if n == 0 {
+				return
+			}
+		}
+		t.Errorf("%s: connections left open", name)
+	}
+
+	target := Request{Method: "GET", URL: server.URL}
+	tURL, _ := url.Parse(server.URL)
+	r := NewRunner(Configuration{Requests: []Request{target}})
+
+	// The connection is closed once the request is done
+	var ready sync.WaitGroup
+	ready.Add(1)
+	start := make(chan struct{})
+	close(start)
+	if _, err := r.sendStandard(context.Background(), target, tURL, &ready, start); err != nil {
+		t.Fatal(err)
+	}
+	waitClosed("request sent")
+
+	// The warm connection is closed if the race is cancelled before the request is released
+	ctx, cancel := context.WithCancel(context.Background())
+	ready.Add(1)
+	done := make(chan error)
+	go func() {
+		_, err := r.sendStandard(ctx, target, tURL, &ready, make(chan struct{}))
+		done <- err
+	}()
+	ready.Wait()
+	cancel()
+	if err := <-done; err == nil {
+		t.Error("sendStandard returned no error once cancelled")
+	}
+	waitClosed("race cancelled")
+}
@@ -0,0 +1,271 @@
+package report
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/TheHackerDev/race-the-web/race"
+)
+
+// HAR 1.2 log, as read by browser developer tools and intercepting proxies
+type harLog struct {
+	Log harContent `json:"log"`
+}
+
+type harContent struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+	Comment string     `json:"comment,omitempty"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+
+	// Custom fields, which HAR allows if they start with an underscore
+	Target int `json:"_target"` // Number of the target in the configuration, from 1
+	Unique int `json:"_unique"` // Number of the unique response, from 1
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harCookie    `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harCookie    `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harBody        `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+	HTTPOnly bool   `json:"httpOnly,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harBody struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// harTimings are in milliseconds. Connections are opened before the requests are released, so the time spent
+// on them is not part of any request, and is reported as -1.
+type harTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	SSL     float64 `json:"ssl"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// WriteHAR writes every request sent and response received as a HAR 1.2 log, ordered by when the requests were
+// released, with their timings taken from the send path. The result must have been recorded by the runner.
+func WriteHAR(w io.Writer, config race.Configuration, result *race.Result) error {
+	if len(result.Exchanges) == 0 && len(result.Responses) > 0 {
+		return fmt.Errorf("the responses were not recorded")
+	}
+
+	exchanges := append([]race.Exchange(nil), result.Exchanges...)
+	sort.SliceStable(exchanges, func(i, j int) bool {
+		return exchanges[i].Timing.Start.Before(exchanges[j].Timing.Start)
+	})
+
+	har := harLog{Log: harContent{
+		Version: "1.2",
+		Creator: harCreator{Name: "race-the-web", Version: "2.0.0"},
+		Entries: []harEntry{},
+		Comment: fmt.Sprintf("%d requests sent to each of %d targets, with %d unique responses", config.Count, len(config.Requests), len(result.Responses)),
+	}}
+	for _, exchange := range exchanges {
+		t := exchange.Sent
+		req := exchange.Response.Request
+		if req == nil {
+			return fmt.Errorf("the request was not kept with its response")
+		}
+		entry := harEntry{
+			StartedDateTime: exchange.Timing.Start.Format(time.RFC3339Nano),
+			Request:         newHARRequest(t, req),
+			Response:        newHARResponse(exchange.Response, exchange.Body),
+			Timings:         newHARTimings(exchange.Timing),
+			Comment:         fmt.Sprintf("Response #%d", exchange.Unique+1),
+			Target:          exchange.Request + 1,
+			Unique:          exchange.Unique + 1,
+		}
+		entry.Time = entry.Timings.Send + entry.Timings.Wait + entry.Timings.Receive
+		har.Log.Entries = append(har.Log.Entries, entry)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(har)
+}
+
+// Function newHARRequest describes the request as it was sent to the target.
+func newHARRequest(t race.Request, req *http.Request) harRequest {
+	harReq := harRequest{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: req.Proto,
+		Cookies:     []harCookie{},
+		Headers:     harHeaders(req.Header),
+		QueryString: []harNameValue{},
+		HeadersSize: -1,
+	}
+	if req.Header.Get("Host") == "" {
+		host := req.Host
+		if host == "" {
+			host = req.URL.Host
+		}
+		harReq.Headers = append([]harNameValue{{"Host", host}}, harReq.Headers...)
+	}
+	if harReq.HTTPVersion == "" {
+		harReq.HTTPVersion = "HTTP/1.1"
+	}
+	for _, c := range req.Cookies() {
+		harReq.Cookies = append(harReq.Cookies, harCookie{Name: c.Name, Value: c.Value})
+	}
+	for name, values := range req.URL.Query() {
+		for _, value := range values {
+			harReq.QueryString = append(harReq.QueryString, harNameValue{name, value})
+		}
+	}
+	sort.SliceStable(harReq.QueryString, func(i, j int) bool { return harReq.QueryString[i].Name < harReq.QueryString[j].Name })
+
+	body := sentBody(t, req)
+	harReq.BodySize = len(body)
+	if body != "" {
+		harReq.PostData = &harPostData{MimeType: req.Header.Get("Content-Type"), Text: body}
+	}
+	return harReq
+}
+
+// Function newHARResponse describes the response, with the body that was read. Bodies that are not text are
+// encoded in base64.
+func newHARResponse(resp *http.Response, body []byte) harResponse {
+	harResp := harResponse{
+		Status:      resp.StatusCode,
+		StatusText:  strings.TrimSpace(strings.TrimPrefix(resp.Status, fmt.Sprint(resp.StatusCode))),
+		HTTPVersion: resp.Proto,
+		Cookies:     []harCookie{},
+		Headers:     harHeaders(resp.Header),
+		Content: harBody{
+			Size:     len(body),
+			MimeType: resp.Header.Get("Content-Type"),
+			Text:     string(body),
+		},
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+	if harResp.StatusText == "" {
+		harResp.StatusText = http.StatusText(resp.StatusCode)
+	}
+	if !utf8.Valid(body) {
+		harResp.Content.Text = base64.StdEncoding.EncodeToString(body)
+		harResp.Content.Encoding = "base64"
+	}
+	for _, c := range resp.Cookies() {
+		cookie := harCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Domain:   c.Domain,
+			HTTPOnly: c.HttpOnly,
+			Secure:   c.Secure,
+		}
+		if !c.Expires.IsZero() {
+			cookie.Expires = c.Expires.Format(time.RFC3339)
+		}
+		harResp.Cookies = append(harResp.Cookies, cookie)
+	}
+	return harResp
+}
+
+// Function newHARTimings converts the phases of a request into HAR timings. Phases that were not recorded take no time.
+func newHARTimings(timing race.RequestTiming) harTimings {
+	phase := func(from, to time.Time) float64 {
+		if from.IsZero() || to.IsZero() || to.Before(from) {
+			return 0
+		}
+		return float64(to.Sub(from)) / float64(time.Millisecond)
+	}
+	lastByte, firstByte := timing.LastByteSent, timing.FirstByteRecvd
+	if lastByte.IsZero() {
+		lastByte = timing.Start
+	}
+	if firstByte.IsZero() {
+		firstByte = lastByte
+	}
+	return harTimings{
+		Blocked: -1,
+		DNS:     -1,
+		Connect: -1,
+		SSL:     -1,
+		Send:    phase(timing.Start, lastByte),
+		Wait:    phase(lastByte, firstByte),
+		Receive: phase(firstByte, timing.Done),
+	}
+}
+
+// Function harHeaders lists headers by name, as HAR keeps them.
+func harHeaders(headers http.Header) []harNameValue {
+	list := []harNameValue{}
+	for name, values := range headers {
+		for _, value := range values {
+			list = append(list, harNameValue{name, value})
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
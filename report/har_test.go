@@ -0,0 +1,150 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/TheHackerDev/race-the-web/race"
+)
+
+func TestWriteHAR(t *testing.T) {
+	config, result := testRace()
+	recordExchanges(result)
+	var out bytes.Buffer
+	if err := WriteHAR(&out, config, result); err != nil {
+		t.Fatalf("WriteHAR returned error: %v", err)
+	}
+	var har harLog
+	if err := json.Unmarshal(out.Bytes(), &har); err != nil {
+		t.Fatalf("log is not JSON: %v", err)
+	}
+	if har.Log.Version != "1.2" || har.Log.Creator.Name != "race-the-web" || len(har.Log.Entries) != 3 {
+		t.Fatalf("log has version %q, creator %q and %d entries", har.Log.Version, har.Log.Creator.Name, len(har.Log.Entries))
+	}
+
+	// Entries are ordered by when the requests were released, the reverse of when their responses arrived
+	tests := []struct {
+		method, url  string
+		target       int
+		unique       int
+		status       int
+		statusText   string
+		responseBody string
+	}{
+		{"GET", "https://shop.test/balance", 2, 3, 200, "OK", "700"},
+		{"POST", "https://shop.test/pay", 1, 2, 409, "Conflict", "refused"},
+		{"POST", "https://shop.test/pay", 1, 1, 200, "OK", "paid"},
+	}
+	for i, test := range tests {
+		entry := har.Log.Entries[i]
+		exchange := result.Exchanges[test.unique-1]
+		if entry.Request.Method != test.method || entry.Request.URL != test.url || entry.Target != test.target || entry.Unique != test.unique {
+			t.Errorf("entry %d is %s %s, for target %d and unique response %d", i, entry.Request.Method, entry.Request.URL, entry.Target, entry.Unique)
+		}
+		if started, err := time.Parse(time.RFC3339Nano, entry.StartedDateTime); err != nil || !started.Equal(exchange.Timing.Start) {
+			t.Errorf("entry %d started at %q, want %v", i, entry.StartedDateTime, exchange.Timing.Start)
+		}
+
+		// Every request is sent 1ms after it was released, and its response is received over 1ms, 2ms later
+		want := harTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1, Send: 1, Wait: 2, Receive: 1}
+		if entry.Timings != want || entry.Time != 4 {
+			t.Errorf("entry %d has timings %+v over %vms, want %+v over 4ms", i, entry.Timings, entry.Time, want)
+		}
+
+		resp := entry.Response
+		if resp.Status != test.status || resp.StatusText != test.statusText || resp.HTTPVersion != "HTTP/1.1" {
+			t.Errorf("entry %d has response %d %q over %s", i, resp.Status, resp.StatusText, resp.HTTPVersion)
+		}
+		if resp.Content.Text != test.responseBody || resp.Content.Size != len(test.responseBody) || resp.BodySize != len(test.responseBody) ||
+			resp.Content.MimeType != "text/plain" || resp.Content.Encoding != "" {
+			t.Errorf("entry %d has response content %+v, want %q", i, resp.Content, test.responseBody)
+		}
+		if entry.Request.Cookies == nil || entry.Request.QueryString == nil || resp.Cookies == nil || resp.Headers == nil {
+			t.Errorf("entry %d has lists encoded as null", i)
+		}
+	}
+
+	// The payment is described as it was sent
+	req := har.Log.Entries[2].Request
+	if req.PostData == nil || req.PostData.Text != "val=1000" || req.PostData.MimeType != "text/plain" || req.BodySize != 8 {
+		t.Errorf("payment sent with post data %+v and body size %d", req.PostData, req.BodySize)
+	}
+	headers := make(map[string]string)
+	for _, header := range req.Headers {
+		headers[header.Name] = header.Value
+	}
+	for name, want := range map[string]string{"Host": "shop.test", "Authorization": "Bearer secret", "Cookie": "session=abc"} {
+		if headers[name] != want {
+			t.Errorf("payment sent with header %s = %q, want %q", name, headers[name], want)
+		}
+	}
+	if len(req.Cookies) != 1 || req.Cookies[0].Name != "session" || req.Cookies[0].Value != "abc" {
+		t.Errorf("payment sent with cookies %+v", req.Cookies)
+	}
+	if cookies := har.Log.Entries[2].Response.Cookies; len(cookies) != 1 || cookies[0].Name != "receipt" || cookies[0].Value != "1" {
+		t.Errorf("payment received cookies %+v", cookies)
+	}
+	if balance := har.Log.Entries[0].Request; balance.PostData != nil || balance.BodySize != 0 {
+		t.Errorf("balance check sent with post data %+v and body size %d", balance.PostData, balance.BodySize)
+	}
+
+	// A result that was not recorded cannot be written
+	_, result = testRace()
+	if err := WriteHAR(&out, config, result); err == nil {
+		t.Errorf("WriteHAR returned no error for responses that were not recorded")
+	}
+}
+
+func TestNewHARResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   string
+		body     []byte
+		text     string
+		encoding string
+	}{
+		{"text", "200 OK", []byte("paid"), "paid", ""},
+		{"binary", "200 OK", []byte{0xff, 0x00}, "/wA=", "base64"},
+		{"no status text", "200", nil, "", ""},
+	}
+	for _, test := range tests {
+		resp := &http.Response{Status: test.status, StatusCode: 200, Proto: "HTTP/2.0", Header: http.Header{}}
+		harResp := newHARResponse(resp, test.body)
+		if harResp.Content.Text != test.text || harResp.Content.Encoding != test.encoding || harResp.Content.Size != len(test.body) {
+			t.Errorf("%s: content %+v, want %q encoded as %q", test.name, harResp.Content, test.text, test.encoding)
+		}
+		if harResp.StatusText != "OK" {
+			t.Errorf("%s: status text %q, want OK", test.name, harResp.StatusText)
+		}
+	}
+}
+
+func TestNewHARTimings(t *testing.T) {
+	start := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := func(ms float64) time.Time {
+		return start.Add(time.Duration(ms * float64(time.Millisecond)))
+	}
+	tests := []struct {
+		name                string
+		timing              race.RequestTiming
+		send, wait, receive float64
+	}{
+		{"every phase", race.RequestTiming{Start: start, FirstByteSent: at(-5), LastByteSent: at(0.5), FirstByteRecvd: at(3), Done: at(3.25)}, 0.5, 2.5, 0.25},
+		{"last byte not recorded", race.RequestTiming{Start: start, FirstByteRecvd: at(3), Done: at(4)}, 0, 3, 1},
+		{"first byte not recorded", race.RequestTiming{Start: start, LastByteSent: at(1), Done: at(4)}, 1, 0, 3},
+		{"not done", race.RequestTiming{Start: start, LastByteSent: at(1)}, 1, 0, 0},
+		{"nothing recorded", race.RequestTiming{}, 0, 0, 0},
+	}
+	for _, test := range tests {
+		timings := newHARTimings(test.timing)
+		if timings.Send != test.send || timings.Wait != test.wait || timings.Receive != test.receive {
+			t.Errorf("%s: timings %+v, want send %v, wait %v and receive %v", test.name, timings, test.send, test.wait, test.receive)
+		}
+		if timings.Blocked != -1 || timings.DNS != -1 || timings.Connect != -1 || timings.SSL != -1 {
+			t.Errorf("%s: timings %+v, want -1 for the connection", test.name, timings)
+		}
+	}
+}
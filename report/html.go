@@ -0,0 +1,217 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TheHackerDev/race-the-web/race"
+)
+
+// htmlBuckets is the number of bars in the timing histogram.
+const htmlBuckets = 30
+
+// htmlData is everything shown in an HTML report.
+type htmlData struct {
+	Config    race.Configuration
+	Result    *race.Result
+	ConfigRaw string // The configuration as run, as JSON
+	Errors    []string
+	Histogram []race.HistogramBucket
+	MaxBucket int // Requests in the fullest bucket of the histogram
+}
+
+// htmlFuncs are the helpers used by the HTML template.
+var htmlFuncs = template.FuncMap{
+	"inc": func(i int) int {
+		return i + 1
+	},
+	// Each unique response keeps its colour throughout the report
+	"colour": func(i int) template.CSS {
+		return template.CSS(fmt.Sprintf("hsl(%d, 65%%, 50%%)", (i*67+210)%360))
+	},
+	"percent": func(part, whole int) string {
+		if whole == 0 {
+			return "0%"
+		}
+		return fmt.Sprintf("%.2f%%", float64(part)*100/float64(whole))
+	},
+	"headers": func(headers http.Header) []string {
+		var lines []string
+		for name, values := range headers {
+			for _, value := range values {
+				lines = append(lines, fmt.Sprintf("%s: %s", name, value))
+			}
+		}
+		sort.Strings(lines)
+		return lines
+	},
+	"lines": func(text string) []string {
+		return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	},
+	// Classes of the lines of a unified diff
+	"diffClass": func(line string) string {
+		switch {
+		case strings.HasPrefix(line, "---"), strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "@@"):
+			return "hunk"
+		case strings.HasPrefix(line, "+"):
+			return "add"
+		case strings.HasPrefix(line, "-"):
+			return "del"
+		}
+		return ""
+	},
+	"similarity": func(similarity float64) string {
+		return fmt.Sprintf("%.0f%%", similarity*100)
+	},
+	// Durations are shown to three significant figures or so
+	"round": func(d time.Duration) time.Duration {
+		switch {
+		case d >= time.Second:
+			return d - d%time.Millisecond
+		case d >= time.Millisecond:
+			return d - d%time.Microsecond
+		}
+		return d
+	},
+	"date": func(t time.Time) string {
+		return t.Format(time.RFC1123)
+	},
+}
+
+// htmlTemplate lays out the report as a single page, with its styles inline so that it can be attached on its own.
+var htmlTemplate = template.Must(template.New("report").Funcs(htmlFuncs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Race The Web report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em auto; max-width: 1100px; padding: 0 1em; color: #222; }
+h1 { margin-bottom: 0.2em; }
+h2 { border-bottom: 1px solid #ddd; padding-bottom: 0.2em; margin-top: 1.8em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #ddd; padding: 0.35em 0.6em; text-align: left; vertical-align: top; }
+th { background: #f4f4f4; }
+td.num { text-align: right; }
+pre { background: #f7f7f7; border: 1px solid #e2e2e2; padding: 0.6em; overflow-x: auto; white-space: pre-wrap; word-break: break-all; margin: 0.4em 0; }
+details { margin: 0.3em 0; }
+summary { cursor: pointer; font-weight: 600; }
+.muted { color: #777; }
+.banner { padding: 0.7em 1em; border-radius: 4px; font-weight: 700; margin: 1em 0; }
+.vulnerable { background: #fde2e2; color: #a40000; }
+.not-vulnerable { background: #e2f5e2; color: #1c6b1c; }
+.inconclusive { background: #fdf3d8; color: #7a5a00; }
+.failures { color: #a40000; }
+.swatch { display: inline-block; width: 0.8em; height: 0.8em; border-radius: 2px; margin-right: 0.3em; }
+.response { border: 1px solid #ddd; border-left-width: 6px; padding: 0.2em 1em 0.6em; margin: 1em 0; }
+.diff .add { background: #e6ffec; }
+.diff .del { background: #ffebe9; }
+.diff .hunk { color: #0550ae; }
+.diff span { display: block; }
+.histogram td { border: none; padding: 1px 0.4em; }
+.histogram .bar { display: flex; height: 1em; }
+.histogram .label { white-space: nowrap; font-family: monospace; font-size: 0.85em; }
+</style>
+</head>
+<body>
+<h1>Race The Web report</h1>
+<p class="muted">Started {{date .Result.Started}}, taking {{round .Result.Duration}}.</p>
+
+{{with .Result.Verdict}}<div class="banner {{if .Vulnerable}}vulnerable{{else if .Inconclusive}}inconclusive{{else}}not-vulnerable{{end}}">Verdict: {{.}}</div>{{end}}
+{{if or .Result.AssertionFailures .Result.ErrorsExceeded}}<ul class="failures">
+{{range .Result.AssertionFailures}}<li>Assertion failed: {{.Message}}</li>
+{{end}}{{if .Result.ErrorsExceeded}}<li>{{.Result.Failed}} requests failed, more than allowed</li>{{end}}
+</ul>{{end}}
+
+<h2>Summary</h2>
+<table>
+<tr><th>Requests sent to each target</th><td>{{.Config.Count}}</td></tr>
+<tr><th>Synchronization</th><td>{{if .Config.Sync}}{{.Config.Sync}}{{else}}none{{end}}</td></tr>
+<tr><th>Unique responses</th><td>{{len .Result.Responses}}</td></tr>
+<tr><th>Failed requests</th><td>{{.Result.Failed}}</td></tr>
+<tr><th>Spread between the first and last request sent</th><td>{{round .Result.Spread}}</td></tr>
+{{if .Result.Interrupted}}<tr><th>Interrupted</th><td>Yes, only the responses received until then are reported</td></tr>{{end}}
+</table>
+
+{{if .Result.Responses}}<h2>Unique responses</h2>
+<table>
+<tr><th>#</th><th>Status</th><th>Count</th><th>Successes</th><th>Length</th><th>Protocol</th><th>Targets</th><th>Last byte sent (min / median / max)</th></tr>
+{{range $i, $resp := .Result.Responses}}<tr>
+<td><a href="#response-{{inc $i}}"><span class="swatch" style="background: {{colour $i}}"></span>{{inc $i}}</a>{{if eq $i $.Result.Baseline}} <span class="muted">(baseline)</span>{{end}}</td>
+<td>{{$resp.Response.StatusCode}}</td>
+<td class="num">{{$resp.Count}}</td>
+<td class="num">{{$resp.Successes}}</td>
+<td class="num">{{$resp.Response.Length}}</td>
+<td>{{$resp.Response.Protocol}}</td>
+<td>{{range $resp.Targets}}{{.Method}} {{.URL}}<br>{{end}}</td>
+<td>{{round $resp.Timing.LastByteOffset.Min}} / {{round $resp.Timing.LastByteOffset.Median}} / {{round $resp.Timing.LastByteOffset.Max}}</td>
+</tr>
+{{end}}</table>{{end}}
+
+{{if .Histogram}}<h2>Timing</h2>
+<p class="muted">Requests by when their last byte was sent, i.e. when the server could act on them, from the first request released.</p>
+<table class="histogram">
+{{range .Histogram}}<tr>
+<td class="label">{{round .Start}} – {{round .End}}</td>
+<td style="width: 100%"><div class="bar" style="width: {{percent .Total $.MaxBucket}}" title="{{.Total}} request(s)">{{$total := .Total}}{{range $i, $count := .Counts}}{{if $count}}<div style="width: {{percent $count $total}}; background: {{colour $i}}" title="Response #{{inc $i}}: {{$count}}"></div>{{end}}{{end}}</div></td>
+<td class="num">{{.Total}}</td>
+</tr>
+{{end}}</table>{{end}}
+
+{{range $i, $resp := .Result.Responses}}<div class="response" id="response-{{inc $i}}" style="border-left-color: {{colour $i}}">
+<h3>Response #{{inc $i}}{{if eq $i $.Result.Baseline}} (baseline){{end}}: status {{$resp.Response.StatusCode}}, received {{$resp.Count}} time(s)</h3>
+{{if $resp.Response.Location}}<p>Redirects to {{$resp.Response.Location}}</p>{{end}}
+<details><summary>Headers</summary><pre>{{range headers $resp.Response.Headers}}{{.}}
+{{end}}</pre></details>
+<details><summary>Body ({{len $resp.Response.Body}} bytes)</summary><pre>{{$resp.Response.Body}}</pre></details>
+{{if $resp.Diff}}<details><summary>Diff against the baseline</summary><pre class="diff">{{range lines $resp.Diff}}<span class="{{diffClass .}}">{{.}}</span>{{end}}</pre></details>{{end}}
+{{if $resp.Outliers}}<details><summary>Similar responses grouped with this one</summary>
+{{range $resp.Outliers}}<p>{{.Count}} response(s), {{similarity .Similarity}} similar</p>
+<pre class="diff">{{range lines .Diff}}<span class="{{diffClass .}}">{{.}}</span>{{end}}</pre>
+{{end}}{{if $resp.MoreOutliers}}<p class="muted">And {{$resp.MoreOutliers}} more.</p>{{end}}
+</details>{{end}}
+</div>
+{{end}}
+
+{{if .Errors}}<h2>Errors</h2>
+<details><summary>{{len .Errors}} error(s)</summary><pre>{{range .Errors}}{{.}}
+{{end}}</pre></details>{{end}}
+
+<h2>Configuration</h2>
+<pre>{{.ConfigRaw}}</pre>
+</body>
+</html>
+`))
+
+// WriteHTML writes the result as a single HTML page with no external assets, to be read in a browser or attached
+// to a finding. It shows every unique response, their differences from the baseline, and when the requests were sent.
+func WriteHTML(w io.Writer, config race.Configuration, result *race.Result) error {
+	var configRaw bytes.Buffer
+	enc := json.NewEncoder(&configRaw)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(config); err != nil {
+		return err
+	}
+	data := htmlData{
+		Config:    config,
+		Result:    result,
+		ConfigRaw: configRaw.String(),
+		Histogram: race.TimingHistogram(result.Responses, htmlBuckets),
+	}
+	for _, err := range result.Errors {
+		data.Errors = append(data.Errors, strings.TrimSpace(err.Error()))
+	}
+	for _, bucket := range data.Histogram {
+		if bucket.Total > data.MaxBucket {
+			data.MaxBucket = bucket.Total
+		}
+	}
+	return htmlTemplate.Execute(w, data)
+}
@@ -0,0 +1,88 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/TheHackerDev/race-the-web/race"
+)
+
+func TestWriteHTML(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(*race.Configuration, *race.Result)
+		want    []string // Parts of the page
+		notWant []string
+	}{
+		{
+			name:   "vulnerable, with a failed assertion",
+			change: func(*race.Configuration, *race.Result) {},
+			want: []string{
+				`<div class="banner vulnerable">Verdict: VULNERABLE</div>`,
+				"<li>Assertion failed: 3 unique responses received, expected at most 1</li>",
+				`<div class="response" id="response-3"`,
+				"<h3>Response #1 (baseline): status 200, received 3 time(s)</h3>",
+				"<summary>1 error(s)</summary><pre>connection reset by peer\n</pre>",
+				"&#34;url&#34;: &#34;https://shop.test/pay&#34;",
+			},
+			notWant: []string{"Interrupted"},
+		},
+		{
+			name: "inconclusive and interrupted",
+			change: func(config *race.Configuration, result *race.Result) {
+				result.Verdict = &race.Verdict{Inconclusive: true}
+				result.AssertionFailures = nil
+				result.Interrupted = true
+			},
+			want:    []string{`<div class="banner inconclusive">Verdict: INCONCLUSIVE</div>`, "<th>Interrupted</th>"},
+			notWant: []string{`class="failures"`},
+		},
+		{
+			name: "no responses",
+			change: func(config *race.Configuration, result *race.Result) {
+				result.Responses = nil
+				result.Verdict = nil
+				result.Errors = nil
+			},
+			want:    []string{"<tr><th>Unique responses</th><td>0</td></tr>"},
+			notWant: []string{"<h2>Unique responses</h2>", "<h2>Errors</h2>", "Verdict:"},
+		},
+		{
+			name: "bodies escaped",
+			change: func(config *race.Configuration, result *race.Result) {
+				result.Responses[0].Response.Body = "<script>alert(1)</script>"
+			},
+			want:    []string{"&lt;script&gt;alert(1)&lt;/script&gt;"},
+			notWant: []string{"<script>"},
+		},
+		{
+			name: "length of a body without a content length",
+			change: func(config *race.Configuration, result *race.Result) {
+				result.Responses[1].Response.Length = -1
+			},
+			want:    []string{"<summary>Body (7 bytes)</summary><pre>refused</pre>"},
+			notWant: []string{"-1 bytes"},
+		},
+	}
+	for _, test := range tests {
+		config, result := testRace()
+		test.change(&config, result)
+		var out bytes.Buffer
+		if err := WriteHTML(&out, config, result); err != nil {
+			t.Errorf("%s: WriteHTML returned error: %v", test.name, err)
+			continue
+		}
+		page := out.String()
+		for _, want := range test.want {
+			if !strings.Contains(page, want) {
+				t.Errorf("%s: page does not contain %q", test.name, want)
+			}
+		}
+		for _, notWant := range test.notWant {
+			if strings.Contains(page, notWant) {
+				t.Errorf("%s: page contains %q", test.name, notWant)
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/TheHackerDev/race-the-web/race"
+)
+
+// JUnit XML elements, as read by Jenkins and most other CI servers
+type junitTestSuite struct {
+	XMLName    xml.Name        `xml:"testsuite"`
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property"`
+	TestCases  []junitTestCase `xml:"testcase"`
+	SystemErr  *junitText      `xml:"system-err,omitempty"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failures  []junitResult `xml:"failure"`
+	Errors    []junitResult `xml:"error"`
+	SystemOut *junitText    `xml:"system-out,omitempty"`
+}
+
+type junitResult struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Details string `xml:",cdata"`
+}
+
+// junitText keeps the line breaks of output readable
+type junitText struct {
+	Text string `xml:",cdata"`
+}
+
+// junitClassName groups the test cases of every run in CI servers.
+const junitClassName = "race-the-web"
+
+// WriteJUnit writes the result as a JUnit XML test suite, with a test case for every request in the configuration.
+// A request fails if it received more successful responses than its success matcher allows.
+// If the configuration has assertions, they are reported in a test case of their own.
+func WriteJUnit(w io.Writer, config race.Configuration, result *race.Result) error {
+	suite := junitTestSuite{
+		Name:      junitClassName,
+		Time:      fmt.Sprintf("%.3f", result.Duration.Seconds()),
+		Timestamp: result.Started.Format("2006-01-02T15:04:05"),
+		Properties: []junitProperty{
+			{Name: "count", Value: fmt.Sprint(config.Count)},
+			{Name: "sync", Value: config.Sync},
+			{Name: "unique_responses", Value: fmt.Sprint(len(result.Responses))},
+			{Name: "failed_requests", Value: fmt.Sprint(result.Failed)},
+			{Name: "spread", Value: result.Spread.String()},
+			{Name: "interrupted", Value: fmt.Sprint(result.Interrupted)},
+		},
+	}
+	if result.Verdict != nil {
+		suite.Properties = append(suite.Properties, junitProperty{Name: "verdict", Value: result.Verdict.String()})
+	}
+
+	// One test case for each request
+	for i, t := range config.Requests {
+		testCase := junitTestCase{
+			Name:      requestName(t),
+			ClassName: junitClassName,
+		}
+
+		var out bytes.Buffer
+		for _, index := range responsesFor(result, t) {
+			resp := result.Responses[index]
+			fmt.Fprintf(&out, "Response #%d: status %d, received %d time(s) in total", index+1, resp.Response.StatusCode, resp.Count)
+			if resp.Successes > 0 {
+				fmt.Fprintf(&out, ", %d success(es)", resp.Successes)
+			}
+			fmt.Fprintln(&out)
+		}
+		if count, ok := successCount(result, i); ok {
+			fmt.Fprintf(&out, "Successes: %d\n", count.Successes)
+			if count.Exceeded {
+				testCase.Failures = append(testCase.Failures, junitResult{
+					Message: fmt.Sprintf("%d successful responses received, expected at most %d", count.Successes, *count.Max),
+					Type:    "verdict",
+					Details: out.String(),
+				})
+			}
+		}
+		testCase.SystemOut = &junitText{out.String()}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+
+	// One test case for the assertions, if there are any
+	if config.Assert != nil {
+		testCase := junitTestCase{
+			Name:      "assertions",
+			ClassName: junitClassName,
+		}
+		for _, failure := range result.AssertionFailures {
+			testCase.Failures = append(testCase.Failures, junitResult{
+				Message: failure.Message,
+				Type:    failure.Assertion,
+			})
+		}
+		if result.ErrorsExceeded {
+			testCase.Errors = append(testCase.Errors, junitResult{
+				Message: fmt.Sprintf("%d requests failed, more than allowed", result.Failed),
+				Type:    "errors",
+			})
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+
+	for _, testCase := range suite.TestCases {
+		suite.Tests++
+		if len(testCase.Failures) > 0 {
+			suite.Failures++
+		} else if len(testCase.Errors) > 0 {
+			suite.Errors++
+		}
+	}
+
+	// Errors from individual requests are kept with the suite
+	if len(result.Errors) > 0 {
+		var errs []string
+		for _, err := range result.Errors {
+			errs = append(errs, strings.TrimSpace(err.Error()))
+		}
+		suite.SystemErr = &junitText{strings.Join(errs, "\n")}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suite); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
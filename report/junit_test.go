@@ -0,0 +1,115 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/TheHackerDev/race-the-web/race"
+)
+
+func TestWriteJUnit(t *testing.T) {
+	tests := []struct {
+		name     string
+		change   func(*race.Configuration, *race.Result)
+		cases    []string // Names of the test cases
+		failures []string // Types of the failures of each test case, joined
+		errors   int
+		verdict  string
+	}{
+		{
+			name:     "vulnerable, with a failed assertion",
+			change:   func(*race.Configuration, *race.Result) {},
+			cases:    []string{"POST https://shop.test/pay", "GET https://shop.test/balance", "assertions"},
+			failures: []string{"verdict", "", "max_unique"},
+			verdict:  "VULNERABLE",
+		},
+		{
+			name: "not vulnerable, without assertions",
+			change: func(config *race.Configuration, result *race.Result) {
+				config.Assert = nil
+				result.AssertionFailures = nil
+				result.Verdict.Vulnerable = false
+				result.Verdict.Requests[0].Exceeded = false
+			},
+			cases:    []string{"POST https://shop.test/pay", "GET https://shop.test/balance"},
+			failures: []string{"", ""},
+			verdict:  "NOT VULNERABLE",
+		},
+		{
+			name: "too many errors",
+			change: func(config *race.Configuration, result *race.Result) {
+				result.AssertionFailures = nil
+				result.ErrorsExceeded = true
+			},
+			cases:    []string{"POST https://shop.test/pay", "GET https://shop.test/balance", "assertions"},
+			failures: []string{"verdict", "", ""},
+			errors:   1,
+			verdict:  "VULNERABLE",
+		},
+	}
+	for _, test := range tests {
+		config, result := testRace()
+		test.change(&config, result)
+		var out bytes.Buffer
+		if err := WriteJUnit(&out, config, result); err != nil {
+			t.Errorf("%s: WriteJUnit returned error: %v", test.name, err)
+			continue
+		}
+		if !strings.HasPrefix(out.String(), xml.Header) {
+			t.Errorf("%s: report does not start with the XML header: %q", test.name, out.String())
+		}
+		var suite junitTestSuite
+		if err := xml.Unmarshal(out.Bytes(), &suite); err != nil {
+			t.Errorf("%s: report is not XML: %v", test.name, err)
+			continue
+		}
+
+		var cases, failures []string
+		failed := 0
+		for _, testCase := range suite.TestCases {
+			cases = append(cases, testCase.Name)
+			var types []string
+			for _, failure := range testCase.Failures {
+				types = append(types, failure.Type)
+			}
+			failures = append(failures, strings.Join(types, ","))
+			if len(testCase.Failures) > 0 {
+				failed++
+			}
+			if testCase.ClassName != "race-the-web" {
+				t.Errorf("%s: test case %q has class name %q", test.name, testCase.Name, testCase.ClassName)
+			}
+		}
+		if strings.Join(cases, "|") != strings.Join(test.cases, "|") {
+			t.Errorf("%s: test cases %q, want %q", test.name, cases, test.cases)
+		}
+		if strings.Join(failures, "|") != strings.Join(test.failures, "|") {
+			t.Errorf("%s: failures %q, want %q", test.name, failures, test.failures)
+		}
+		if suite.Tests != len(test.cases) || suite.Failures != failed || suite.Errors != test.errors {
+			t.Errorf("%s: suite counts %d tests, %d failures and %d errors, want %d, %d and %d",
+				test.name, suite.Tests, suite.Failures, suite.Errors, len(test.cases), failed, test.errors)
+		}
+
+		properties := make(map[string]string)
+		for _, property := range suite.Properties {
+			properties[property.Name] = property.Value
+		}
+		for name, want := range map[string]string{"count": "5", "sync": "last-byte", "unique_responses": "3", "failed_requests": "1", "verdict": test.verdict} {
+			if properties[name] != want {
+				t.Errorf("%s: property %s = %q, want %q", test.name, name, properties[name], want)
+			}
+		}
+		if suite.Time != "1.500" || suite.Timestamp != "2017-06-01T12:00:00" {
+			t.Errorf("%s: suite took %s at %s, want 1.500 at 2017-06-01T12:00:00", test.name, suite.Time, suite.Timestamp)
+		}
+		if suite.SystemErr == nil || suite.SystemErr.Text != "connection reset by peer" {
+			t.Errorf("%s: system-err = %+v, want the error of the request that failed", test.name, suite.SystemErr)
+		}
+		if want := "Response #1: status 200, received 3 time(s) in total, 3 success(es)\n"; !strings.Contains(suite.TestCases[0].SystemOut.Text, want) {
+			t.Errorf("%s: system-out of the first request = %q, want it to contain %q", test.name, suite.TestCases[0].SystemOut.Text, want)
+		}
+	}
+}
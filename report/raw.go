@@ -0,0 +1,157 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/TheHackerDev/race-the-web/race"
+)
+
+// IndexFile is the name of the index written alongside the raw requests and responses.
+const IndexFile = "index.json"
+
+// rawIndex maps every request and response saved to the unique response that it was grouped with.
+// Targets and unique responses are numbered from 1, as they are shown on the command line.
+type rawIndex struct {
+	Started   time.Time          `json:"started"`
+	Count     int                `json:"count"` // Requests sent to each target
+	Exchanges []rawIndexExchange `json:"exchanges"`
+	Responses []rawIndexResponse `json:"responses"`
+}
+
+type rawIndexExchange struct {
+	Number       int                `json:"number"`
+	Target       int                `json:"target"`
+	Method       string             `json:"method"`
+	URL          string             `json:"url"`
+	Unique       int                `json:"unique"`
+	StatusCode   int                `json:"status_code"`
+	RequestFile  string             `json:"request_file"`
+	ResponseFile string             `json:"response_file"`
+	Timing       race.RequestTiming `json:"timing"`
+}
+
+type rawIndexResponse struct {
+	Unique     int `json:"unique"`
+	StatusCode int `json:"status_code"`
+	Count      int `json:"count"`
+	Successes  int `json:"successes"`
+}
+
+// SaveRaw writes every request sent and response received to the directory, creating it if needed, along with an index.
+// Each pair is numbered in the order the responses arrived, as NNNN-request.http and NNNN-response.http, in HTTP/1.1
+// wire format. Response bodies are saved as they were read, after any transfer or content encoding was removed.
+// The result must have been recorded by the runner.
+func SaveRaw(dir string, config race.Configuration, result *race.Result) error {
+	if len(result.Exchanges) == 0 && len(result.Responses) > 0 {
+		return fmt.Errorf("the responses were not recorded")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	index := rawIndex{
+		Started:   result.Started,
+		Count:     config.Count,
+		Exchanges: []rawIndexExchange{},
+		Responses: []rawIndexResponse{},
+	}
+	width := len(fmt.Sprint(len(result.Exchanges)))
+	if width < 4 {
+		width = 4
+	}
+	for i, exchange := range result.Exchanges {
+		t := exchange.Sent
+		entry := rawIndexExchange{
+			Number:       i + 1,
+			Target:       exchange.Request + 1,
+			Method:       t.Method,
+			URL:          t.URL,
+			Unique:       exchange.Unique + 1,
+			StatusCode:   exchange.Response.StatusCode,
+			RequestFile:  fmt.Sprintf("%0*d-request.http", width, i+1),
+			ResponseFile: fmt.Sprintf("%0*d-response.http", width, i+1),
+			Timing:       exchange.Timing,
+		}
+
+		rawRequest, err := dumpRequest(t, exchange.Response.Request)
+		if err != nil {
+			return fmt.Errorf("could not save request %d: %v", i+1, err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, entry.RequestFile), rawRequest, 0644); err != nil {
+			return err
+		}
+		rawResponse, err := dumpResponse(exchange.Response, exchange.Body)
+		if err != nil {
+			return fmt.Errorf("could not save response %d: %v", i+1, err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, entry.ResponseFile), rawResponse, 0644); err != nil {
+			return err
+		}
+		index.Exchanges = append(index.Exchanges, entry)
+	}
+	for i, resp := range result.Responses {
+		index.Responses = append(index.Responses, rawIndexResponse{
+			Unique:     i + 1,
+			StatusCode: resp.Response.StatusCode,
+			Count:      resp.Count,
+			Successes:  resp.Successes,
+		})
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(index); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, IndexFile), buf.Bytes(), 0644)
+}
+
+// Function dumpRequest renders the request as it was sent to the target. It is serialized the same way as by the
+// last-byte engine, so that no headers are added that the request was not sent with, such as Accept-Encoding.
+func dumpRequest(t race.Request, req *http.Request) ([]byte, error) {
+	if req == nil {
+		return nil, fmt.Errorf("the request was not kept with its response")
+	}
+	out := req.WithContext(context.Background())
+	body := sentBody(t, req)
+	out.Body = nil
+	out.ContentLength = int64(len(body))
+	if body != "" {
+		out.Body = ioutil.NopCloser(strings.NewReader(body))
+	}
+	var raw bytes.Buffer
+	if err := out.Write(&raw); err != nil {
+		return nil, err
+	}
+	return raw.Bytes(), nil
+}
+
+// Function sentBody returns the body of a request as it was sent. The request's own body has been consumed by then,
+// so the body of the copy of the target that was sent is used, unless a redirect was followed to another request.
+func sentBody(t race.Request, req *http.Request) string {
+	if req.Method == t.Method && req.URL.String() == t.URL {
+		return t.Body
+	}
+	return ""
+}
+
+// Function dumpResponse renders the response with the body that was read, so that the file can be parsed again.
+func dumpResponse(resp *http.Response, body []byte) ([]byte, error) {
+	out := *resp
+	out.TransferEncoding = nil
+	out.ContentLength = int64(len(body))
+	out.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return httputil.DumpResponse(&out, true)
+}
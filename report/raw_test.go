@@ -0,0 +1,189 @@
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheHackerDev/race-the-web/race"
+)
+
+// Function recordExchanges records an exchange in the result of testRace for each unique response, as the runner would.
+// The responses arrive in order, though the requests were released in reverse order, 1ms apart.
+func recordExchanges(result *race.Result) {
+	for i, resp := range result.Responses {
+		t := resp.Targets[0]
+		req, err := http.NewRequest(t.Method, t.URL, strings.NewReader(t.Body))
+		if err != nil {
+			panic(err)
+		}
+		req.Header = requestHeaders(t)
+
+		header := http.Header{"Content-Type": {"text/plain"}}
+		for name, values := range resp.Response.Headers {
+			header[name] = values
+		}
+		start := result.Started.Add(time.Duration(len(result.Responses)-i) * time.Millisecond)
+		result.Exchanges = append(result.Exchanges, race.Exchange{
+			Request: i / 2, // The first two unique responses answered the payment
+			Unique:  i,
+			Timing: race.RequestTiming{
+				Start:          start,
+				LastByteSent:   start.Add(time.Millisecond),
+				FirstByteRecvd: start.Add(3 * time.Millisecond),
+				Done:           start.Add(4 * time.Millisecond),
+			},
+			Response: &http.Response{
+				Status:           fmt.Sprintf("%d %s", resp.Response.StatusCode, http.StatusText(resp.Response.StatusCode)),
+				StatusCode:       resp.Response.StatusCode,
+				Proto:            "HTTP/1.1",
+				ProtoMajor:       1,
+				ProtoMinor:       1,
+				Header:           header,
+				TransferEncoding: []string{"chunked"},
+				ContentLength:    -1,
+				Request:          req,
+			},
+			Body: []byte(resp.Response.Body),
+			Sent: t,
+		})
+	}
+}
+
+func TestSaveRaw(t *testing.T) {
+	dir, err := ioutil.TempDir("", "race-raw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config, result := testRace()
+	recordExchanges(result)
+	if err := SaveRaw(filepath.Join(dir, "run"), config, result); err != nil {
+		t.Fatalf("SaveRaw returned error: %v", err)
+	}
+
+	var index rawIndex
+	data, err := ioutil.ReadFile(filepath.Join(dir, "run", IndexFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		t.Fatalf("index is not JSON: %v", err)
+	}
+	if !index.Started.Equal(result.Started) || index.Count != 5 || len(index.Exchanges) != 3 || len(index.Responses) != 3 {
+		t.Fatalf("index = %+v, want the 3 exchanges and responses of 5 requests started at %v", index, result.Started)
+	}
+	if response := index.Responses[0]; response.Unique != 1 || response.StatusCode != 200 || response.Count != 3 || response.Successes != 3 {
+		t.Errorf("first unique response indexed as %+v", response)
+	}
+
+	tests := []struct {
+		method, url string
+		target      int
+		headers     []string // Headers of the request saved
+		body        string   // Body of the request saved
+		status      int
+		response    string // Body of the response saved
+	}{
+		{"POST", "https://shop.test/pay", 1, []string{"Authorization: Bearer secret", "Content-Length: 8", "Content-Type: text/plain", "Cookie: session=abc"}, "val=1000", 200, "paid"},
+		{"POST", "https://shop.test/pay", 1, nil, "val=1000", 409, "refused"},
+		{"GET", "https://shop.test/balance", 2, nil, "", 200, "700"},
+	}
+	for i, test := range tests {
+		entry := index.Exchanges[i]
+		if entry.Number != i+1 || entry.Target != test.target || entry.Unique != i+1 || entry.Method != test.method || entry.URL != test.url || entry.StatusCode != test.status {
+			t.Errorf("exchange %d indexed as %+v", i+1, entry)
+		}
+		if entry.RequestFile != fmt.Sprintf("%04d-request.http", i+1) || entry.ResponseFile != fmt.Sprintf("%04d-response.http", i+1) {
+			t.Errorf("exchange %d saved as %s and %s", i+1, entry.RequestFile, entry.ResponseFile)
+		}
+		if !entry.Timing.Start.Equal(result.Exchanges[i].Timing.Start) {
+			t.Errorf("exchange %d indexed as started at %v, want %v", i+1, entry.Timing.Start, result.Exchanges[i].Timing.Start)
+		}
+
+		// Requests are saved as they were sent, so they can be parsed again
+		rawRequest, err := ioutil.ReadFile(filepath.Join(dir, "run", entry.RequestFile))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(rawRequest)))
+		if err != nil {
+			t.Errorf("request %d cannot be parsed: %v\n%s", i+1, err, rawRequest)
+			continue
+		}
+		body, _ := ioutil.ReadAll(req.Body)
+		if req.Method != test.method || req.Host != "shop.test" || string(body) != test.body {
+			t.Errorf("request %d saved as %s to %s with body %q", i+1, req.Method, req.Host, body)
+		}
+		if req.Header.Get("Accept-Encoding") != "" {
+			t.Errorf("request %d saved with a header that was not sent: Accept-Encoding: %s", i+1, req.Header.Get("Accept-Encoding"))
+		}
+		for _, header := range test.headers {
+			if !strings.Contains(string(rawRequest), "\r\n"+header+"\r\n") {
+				t.Errorf("request %d saved without header %q:\n%s", i+1, header, rawRequest)
+			}
+		}
+
+		// Responses are saved with the body that was read, without a transfer encoding
+		rawResponse, err := ioutil.ReadFile(filepath.Join(dir, "run", entry.ResponseFile))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(rawResponse)), nil)
+		if err != nil {
+			t.Errorf("response %d cannot be parsed: %v\n%s", i+1, err, rawResponse)
+			continue
+		}
+		body, _ = ioutil.ReadAll(resp.Body)
+		if resp.StatusCode != test.status || string(body) != test.response || len(resp.TransferEncoding) > 0 {
+			t.Errorf("response %d saved with status %d, body %q and transfer encoding %q", i+1, resp.StatusCode, body, resp.TransferEncoding)
+		}
+	}
+}
+
+func TestSaveRawNotRecorded(t *testing.T) {
+	dir, err := ioutil.TempDir("", "race-raw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config, result := testRace()
+	if err := SaveRaw(dir, config, result); err == nil {
+		t.Errorf("SaveRaw returned no error for responses that were not recorded")
+	}
+	if err := SaveRaw(dir, config, &race.Result{}); err != nil {
+		t.Errorf("SaveRaw returned error for a race without responses: %v", err)
+	}
+}
+
+func TestSentBody(t *testing.T) {
+	target := race.Request{Method: "POST", URL: "https://shop.test/pay", Body: "val=1000"}
+	tests := []struct {
+		name        string
+		method, url string
+		body        string
+	}{
+		{"request sent", "POST", "https://shop.test/pay", "val=1000"},
+		{"redirect followed", "GET", "https://shop.test/receipt", ""},
+		{"redirect to the same URL", "GET", "https://shop.test/pay", ""},
+	}
+	for _, test := range tests {
+		req, err := http.NewRequest(test.method, test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body := sentBody(target, req); body != test.body {
+			t.Errorf("%s: sentBody = %q, want %q", test.name, body, test.body)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Package report writes the results of race tests in formats read by other tools, such as CI servers.
+package report
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/TheHackerDev/race-the-web/race"
+)
+
+// Report formats
+const (
+	FormatJUnit = "junit"
+	FormatSARIF = "sarif"
+	FormatHTML  = "html"
+	FormatHAR   = "har"
+)
+
+// Formats lists the supported report formats.
+var Formats = []string{FormatJUnit, FormatSARIF, FormatHTML, FormatHAR}
+
+// Write writes a report of the result, for the configuration that was run, in the given format.
+func Write(w io.Writer, format string, config race.Configuration, result *race.Result) error {
+	switch format {
+	case FormatJUnit:
+		return WriteJUnit(w, config, result)
+	case FormatSARIF:
+		return WriteSARIF(w, config, result)
+	case FormatHTML:
+		return WriteHTML(w, config, result)
+	case FormatHAR:
+		return WriteHAR(w, config, result)
+	}
+	return fmt.Errorf("unknown report format %q", format)
+}
+
+// Recorded reports whether a report format needs every response to be recorded by the runner.
+func Recorded(format string) bool {
+	return format == FormatHAR
+}
+
+// ContentType returns the MIME type of a report format.
+func ContentType(format string) string {
+	switch format {
+	case FormatJUnit:
+		return "application/xml"
+	case FormatSARIF:
+		return "application/sarif+json"
+	case FormatHTML:
+		return "text/html; charset=utf-8"
+	case FormatHAR:
+		return "application/json"
+	}
+	return "application/octet-stream"
+}
+
+// Function requestName names a request by its method and URL.
+func requestName(t race.Request) string {
+	return fmt.Sprintf("%s %s", t.Method, t.URL)
+}
+
+// Function responsesFor returns the indexes of the unique responses received by a request.
+func responsesFor(result *race.Result, t race.Request) []int {
+	var indexes []int
+	for i, resp := range result.Responses {
+		for _, target := range resp.Targets {
+			if reflect.DeepEqual(target, t) {
+				indexes = append(indexes, i)
+				break
+			}
+		}
+	}
+	return indexes
+}
+
+// Function successCount returns the successes observed for the request at index i, if it has a success matcher.
+func successCount(result *race.Result, i int) (race.SuccessCount, bool) {
+	if result.Verdict == nil {
+		return race.SuccessCount{}, false
+	}
+	for _, count := range result.Verdict.Requests {
+		if count.Request == i {
+			return count, true
+		}
+	}
+	return race.SuccessCount{}, false
+}
@@ -0,0 +1,64 @@
+package report
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/TheHackerDev/race-the-web/race"
+)
+
+// Function testRace returns the configuration and result of a race of two requests: a payment that succeeded more
+// often than its success matcher allows, and a balance check without a matcher.
+func testRace() (race.Configuration, *race.Result) {
+	one := 1
+	pay := race.Request{
+		Method:  "POST",
+		URL:     "https://shop.test/pay",
+		Headers: []string{"Authorization: Bearer secret", "Content-Type: text/plain"},
+		Cookies: []string{"session=abc"},
+		Body:    "val=1000",
+		Success: &race.SuccessMatcher{Status: []int{200}, Max: &one},
+	}
+	balance := race.Request{Method: "GET", URL: "https://shop.test/balance"}
+	config := race.Configuration{
+		Count:    5,
+		Sync:     race.SyncLastByte,
+		Requests: []race.Request{pay, balance},
+		Assert:   &race.Assertions{MaxUnique: &one},
+	}
+
+	result := &race.Result{
+		Responses: []race.UniqueResponseInfo{
+			{
+				Response:  race.UniqueResponseData{StatusCode: 200, Length: 4, Protocol: "HTTP/1.1", Body: "paid", Headers: http.Header{"Set-Cookie": {"receipt=1"}}},
+				Targets:   []race.Request{pay},
+				Count:     3,
+				Successes: 3,
+			},
+			{
+				Response: race.UniqueResponseData{StatusCode: 409, Length: 7, Protocol: "HTTP/1.1", Body: "refused"},
+				Targets:  []race.Request{pay},
+				Count:    1,
+			},
+			{
+				Response: race.UniqueResponseData{StatusCode: 200, Length: 3, Protocol: "HTTP/1.1", Body: "700"},
+				Targets:  []race.Request{balance},
+				Count:    5,
+			},
+		},
+		Errors:  []error{errors.New("connection reset by peer\n")},
+		Failed:  1,
+		Spread:  2 * time.Millisecond,
+		Started: time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC),
+		Verdict: &race.Verdict{
+			Vulnerable: true,
+			Requests:   []race.SuccessCount{{Request: 0, Method: pay.Method, URL: pay.URL, Successes: 3, Max: &one, Exceeded: true}},
+		},
+		AssertionFailures: []race.AssertionFailure{
+			{Assertion: race.AssertMaxUnique, Message: "3 unique responses received, expected at most 1"},
+		},
+		Duration: 1500 * time.Millisecond,
+	}
+	return config, result
+}
@@ -0,0 +1,375 @@
+package report
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/TheHackerDev/race-the-web/race"
+)
+
+// SARIF 2.1.0 log, as read by code scanning dashboards
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool        sarifTool         `json:"tool"`
+	Invocations []sarifInvocation `json:"invocations"`
+	Results     []sarifResult     `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string         `json:"id"`
+	Name             string         `json:"name"`
+	ShortDescription sarifMessage   `json:"shortDescription"`
+	FullDescription  sarifMessage   `json:"fullDescription"`
+	Help             sarifMessage   `json:"help"`
+	Properties       sarifRuleProps `json:"properties"`
+}
+
+type sarifRuleProps struct {
+	Tags             []string `json:"tags"`
+	SecuritySeverity string   `json:"security-severity"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful        bool                `json:"executionSuccessful"`
+	StartTimeUTC               string              `json:"startTimeUtc"`
+	EndTimeUTC                 string              `json:"endTimeUtc"`
+	ToolExecutionNotifications []sarifNotification `json:"toolExecutionNotifications,omitempty"`
+}
+
+type sarifNotification struct {
+	Level   string       `json:"level"`
+	Message sarifMessage `json:"message"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+	WebRequest          *sarifWebRequest  `json:"webRequest,omitempty"`
+	WebResponse         *sarifWebResponse `json:"webResponse,omitempty"`
+	Properties          sarifEvidence     `json:"properties"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifWebRequest struct {
+	Method  string            `json:"method"`
+	Target  string            `json:"target"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    *sarifContent     `json:"body,omitempty"`
+}
+
+type sarifWebResponse struct {
+	Protocol   string            `json:"protocol,omitempty"`
+	StatusCode int               `json:"statusCode"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       *sarifContent     `json:"body,omitempty"`
+}
+
+type sarifContent struct {
+	Text string `json:"text"`
+}
+
+// sarifEvidence is kept in the property bag of each result, so that the finding can be judged without running it again.
+type sarifEvidence struct {
+	Count     int                 `json:"count"` // Requests sent to each target
+	Responses []sarifResponseInfo `json:"responses"`
+}
+
+type sarifResponseInfo struct {
+	Response   int    `json:"response"` // Number of the unique response, as shown by the command line
+	StatusCode int    `json:"statusCode"`
+	Count      int    `json:"count"`
+	Successes  int    `json:"successes"`
+	Body       string `json:"body"` // Sample of the body, cut short if it is long
+}
+
+// Rule IDs are stable, so that dashboards can track a finding from one run to the next.
+// New rules must be added at the end.
+const (
+	ruleSuccessExceeded = "RTW001"
+	ruleMaxUnique       = "RTW002"
+	ruleMaxSuccesses    = "RTW003"
+	ruleNo5xx           = "RTW004"
+)
+
+var sarifRules = []sarifRule{
+	{
+		ID:               ruleSuccessExceeded,
+		Name:             "RaceConditionExploited",
+		ShortDescription: sarifMessage{"Request succeeded more often than allowed"},
+		FullDescription:  sarifMessage{"A request sent many times at once met its success matcher more often than its max allows, so its limit can be bypassed by racing requests."},
+		Help:             sarifMessage{"Make the check and the update of the limited resource atomic, for example with a database transaction or lock."},
+		Properties:       sarifRuleProps{Tags: []string{"security", "race-condition"}, SecuritySeverity: "7.5"},
+	},
+	{
+		ID:               ruleMaxUnique,
+		Name:             "TooManyUniqueResponses",
+		ShortDescription: sarifMessage{"More unique responses than allowed"},
+		FullDescription:  sarifMessage{"Identical requests sent at once received more unique responses than the max_unique assertion allows, which can be a sign of a race condition."},
+		Help:             sarifMessage{"Compare the unique responses to find out which requests were handled differently."},
+		Properties:       sarifRuleProps{Tags: []string{"security", "race-condition"}, SecuritySeverity: "5.0"},
+	},
+	{
+		ID:               ruleMaxSuccesses,
+		Name:             "TooManySuccesses",
+		ShortDescription: sarifMessage{"More successful responses than allowed"},
+		FullDescription:  sarifMessage{"Requests sent at once met their success matchers more often in total than the max_successes assertion allows, so a limit can be bypassed by racing requests."},
+		Help:             sarifMessage{"Make the check and the update of the limited resource atomic, for example with a database transaction or lock."},
+		Properties:       sarifRuleProps{Tags: []string{"security", "race-condition"}, SecuritySeverity: "7.5"},
+	},
+	{
+		ID:               ruleNo5xx,
+		Name:             "ServerErrorUnderRace",
+		ShortDescription: sarifMessage{"Server error under concurrent requests"},
+		FullDescription:  sarifMessage{"Requests sent at once received responses with a 5xx status code, which the no_5xx assertion forbids. The server may fail to handle concurrent requests safely."},
+		Help:             sarifMessage{"Check the server logs for errors such as deadlocks or constraint violations while the requests were handled."},
+		Properties:       sarifRuleProps{Tags: []string{"security", "race-condition", "reliability"}, SecuritySeverity: "4.0"},
+	},
+}
+
+// assertionRules maps the assertions to the rules that report them.
+var assertionRules = map[string]string{
+	race.AssertMaxUnique:    ruleMaxUnique,
+	race.AssertMaxSuccesses: ruleMaxSuccesses,
+	race.AssertNo5xx:        ruleNo5xx,
+}
+
+// maxSampleBody is the most bytes of a response body kept as evidence.
+const maxSampleBody = 2048
+
+// WriteSARIF writes the result as a SARIF 2.1.0 log. Every race condition confirmed by the verdict or by a failed assertion
+// is reported as a result, with the requests involved and the responses received as evidence.
+func WriteSARIF(w io.Writer, config race.Configuration, result *race.Result) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "race-the-web",
+			InformationURI: "https://github.com/TheHackerDev/race-the-web",
+			Rules:          sarifRules,
+		}},
+		Invocations: []sarifInvocation{{
+			ExecutionSuccessful: !result.Interrupted && !result.ErrorsExceeded,
+			StartTimeUTC:        result.Started.UTC().Format(time.RFC3339),
+			EndTimeUTC:          result.Started.Add(result.Duration).UTC().Format(time.RFC3339),
+		}},
+		Results: []sarifResult{},
+	}
+	for _, err := range result.Errors {
+		run.Invocations[0].ToolExecutionNotifications = append(run.Invocations[0].ToolExecutionNotifications, sarifNotification{
+			Level:   "warning",
+			Message: sarifMessage{strings.TrimSpace(err.Error())},
+		})
+	}
+
+	// Requests that received more successes than allowed
+	if result.Verdict != nil {
+		for _, count := range result.Verdict.Requests {
+			if !count.Exceeded {
+				continue
+			}
+			t := config.Requests[count.Request]
+			message := fmt.Sprintf("%s %s succeeded %d times when sent %d times at once, expected at most %d.", t.Method, t.URL, count.Successes, config.Count, *count.Max)
+			run.Results = append(run.Results, newSARIFResult(ruleSuccessExceeded, message, config, result, []race.Request{t}))
+		}
+	}
+
+	// Failed assertions, which cover every request
+	for _, failure := range result.AssertionFailures {
+		rule, ok := assertionRules[failure.Assertion]
+		if !ok {
+			continue
+		}
+		message := fmt.Sprintf("Assertion %s failed: %s.", failure.Assertion, failure.Message)
+		run.Results = append(run.Results, newSARIFResult(rule, message, config, result, config.Requests))
+	}
+
+	sarif := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarif)
+}
+
+// Function newSARIFResult builds a result for the rule, located at the URLs of the requests involved.
+// A result for a single request also describes that request, and the response that most often met its success matcher.
+func newSARIFResult(rule, message string, config race.Configuration, result *race.Result, requests []race.Request) sarifResult {
+	res := sarifResult{
+		RuleID:    rule,
+		RuleIndex: ruleIndex(rule),
+		Level:     "error",
+		Message:   sarifMessage{message},
+		Properties: sarifEvidence{
+			Count:     config.Count,
+			Responses: []sarifResponseInfo{},
+		},
+	}
+
+	// Findings are matched across runs by the rule and the requests involved
+	fingerprint := sha256.New()
+	fmt.Fprintln(fingerprint, rule)
+	seen := make(map[int]bool)
+	for _, t := range requests {
+		res.Locations = append(res.Locations, sarifLocation{sarifPhysicalLocation{sarifArtifactLocation{t.URL}}})
+		fmt.Fprintln(fingerprint, t.Method, t.URL)
+		for _, index := range responsesFor(result, t) {
+			if seen[index] {
+				continue
+			}
+			seen[index] = true
+			resp := result.Responses[index]
+			res.Properties.Responses = append(res.Properties.Responses, sarifResponseInfo{
+				Response:   index + 1,
+				StatusCode: resp.Response.StatusCode,
+				Count:      resp.Count,
+				Successes:  resp.Successes,
+				Body:       sampleBody(resp.Response.Body),
+			})
+		}
+	}
+	sort.Slice(res.Properties.Responses, func(i, j int) bool {
+		return res.Properties.Responses[i].Response < res.Properties.Responses[j].Response
+	})
+	res.PartialFingerprints = map[string]string{
+		"raceTheWeb/v1": hex.EncodeToString(fingerprint.Sum(nil)),
+	}
+
+	if len(requests) == 1 {
+		t := requests[0]
+		res.WebRequest = &sarifWebRequest{
+			Method:  t.Method,
+			Target:  t.URL,
+			Headers: sarifHeaders(requestHeaders(t)),
+		}
+		if t.Body != "" {
+			res.WebRequest.Body = &sarifContent{t.Body}
+		}
+
+		// The response that best shows the finding: the most successful, then the most common
+		best := -1
+		for _, index := range responsesFor(result, t) {
+			resp := result.Responses[index]
+			if best < 0 || resp.Successes > result.Responses[best].Successes ||
+				resp.Successes == result.Responses[best].Successes && resp.Count > result.Responses[best].Count {
+				best = index
+			}
+		}
+		if best >= 0 {
+			data := result.Responses[best].Response
+			res.WebResponse = &sarifWebResponse{
+				Protocol:   data.Protocol,
+				StatusCode: data.StatusCode,
+				Headers:    sarifHeaders(data.Headers),
+				Body:       &sarifContent{sampleBody(data.Body)},
+			}
+		}
+	}
+	return res
+}
+
+// Function ruleIndex returns the position of the rule in the rules of the driver.
+func ruleIndex(id string) int {
+	for i, rule := range sarifRules {
+		if rule.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// sarifRedacted are the headers whose values are left out of SARIF logs, as they hold session tokens and credentials
+// that would otherwise be shown to everyone with access to the code scanning dashboard.
+var sarifRedacted = map[string]bool{
+	"Authorization":       true,
+	"Cookie":              true,
+	"Proxy-Authorization": true,
+	"Set-Cookie":          true,
+}
+
+// Function sarifHeaders converts headers to the single values that SARIF holds, joining repeated headers, and
+// redacting those in sarifRedacted.
+func sarifHeaders(headers http.Header) map[string]string {
+	if len(headers) == 0 {
+		return nil
+	}
+	converted := make(map[string]string, len(headers))
+	for name, values := range headers {
+		if sarifRedacted[http.CanonicalHeaderKey(name)] {
+			converted[name] = "[redacted]"
+			continue
+		}
+		converted[name] = strings.Join(values, ", ")
+	}
+	return converted
+}
+
+// Function requestHeaders parses the headers set for a request, along with its cookies.
+func requestHeaders(t race.Request) http.Header {
+	headers := make(http.Header)
+	for _, header := range t.Headers {
+		split := strings.SplitN(header, ":", 2)
+		if len(split) == 2 {
+			headers.Add(strings.TrimSpace(split[0]), strings.TrimSpace(split[1]))
+		}
+	}
+	if len(t.Cookies) > 0 {
+		headers.Set("Cookie", strings.Join(t.Cookies, "; "))
+	}
+	return headers
+}
+
+// Function sampleBody cuts a body short to keep as evidence, without splitting a UTF-8 character.
+func sampleBody(body string) string {
+	if len(body) <= maxSampleBody {
+		return body
+	}
+	// A character is at most utf8.UTFMax bytes long, so binary bodies are not backed off further
+	cut := maxSampleBody
+	for cut > maxSampleBody-utf8.UTFMax+1 && !utf8.RuneStart(body[cut]) {
+		cut--
+	}
+	return body[:cut] + fmt.Sprintf("... (%d more bytes)", len(body)-cut)
+}
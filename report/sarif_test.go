@@ -0,0 +1,204 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/TheHackerDev/race-the-web/race"
+)
+
+func TestWriteSARIF(t *testing.T) {
+	tests := []struct {
+		name       string
+		change     func(*race.Configuration, *race.Result)
+		rules      []string // Rules of the results
+		successful bool
+	}{
+		{
+			name:       "vulnerable, with a failed assertion",
+			change:     func(*race.Configuration, *race.Result) {},
+			rules:      []string{ruleSuccessExceeded, ruleMaxUnique},
+			successful: true,
+		},
+		{
+			name: "not vulnerable",
+			change: func(config *race.Configuration, result *race.Result) {
+				result.AssertionFailures = nil
+				result.Verdict.Vulnerable = false
+				result.Verdict.Requests[0].Exceeded = false
+			},
+			successful: true,
+		},
+		{
+			name: "interrupted",
+			change: func(config *race.Configuration, result *race.Result) {
+				result.AssertionFailures = []race.AssertionFailure{{Assertion: race.AssertNo5xx, Message: "1 responses received with a 5xx status code, expected none"}}
+				result.Interrupted = true
+			},
+			rules: []string{ruleSuccessExceeded, ruleNo5xx},
+		},
+	}
+	for _, test := range tests {
+		config, result := testRace()
+		test.change(&config, result)
+		var out bytes.Buffer
+		if err := WriteSARIF(&out, config, result); err != nil {
+			t.Errorf("%s: WriteSARIF returned error: %v", test.name, err)
+			continue
+		}
+		var log sarifLog
+		if err := json.Unmarshal(out.Bytes(), &log); err != nil {
+			t.Errorf("%s: report is not JSON: %v", test.name, err)
+			continue
+		}
+		if log.Version != "2.1.0" || log.Schema != "https://json.schemastore.org/sarif-2.1.0.json" || len(log.Runs) != 1 {
+			t.Errorf("%s: log has version %q, schema %q and %d runs", test.name, log.Version, log.Schema, len(log.Runs))
+			continue
+		}
+		run := log.Runs[0]
+		if len(run.Tool.Driver.Rules) != len(sarifRules) || run.Tool.Driver.Name != "race-the-web" {
+			t.Errorf("%s: driver %q has %d rules, want %d", test.name, run.Tool.Driver.Name, len(run.Tool.Driver.Rules), len(sarifRules))
+		}
+		if run.Results == nil {
+			t.Errorf("%s: results encoded as null", test.name)
+		}
+
+		var rules []string
+		for _, res := range run.Results {
+			rules = append(rules, res.RuleID)
+			if run.Tool.Driver.Rules[res.RuleIndex].ID != res.RuleID {
+				t.Errorf("%s: result of rule %s has the index of rule %s", test.name, res.RuleID, run.Tool.Driver.Rules[res.RuleIndex].ID)
+			}
+			if res.Level != "error" || res.PartialFingerprints["raceTheWeb/v1"] == "" {
+				t.Errorf("%s: result of rule %s has level %q and fingerprints %v", test.name, res.RuleID, res.Level, res.PartialFingerprints)
+			}
+		}
+		if strings.Join(rules, " ") != strings.Join(test.rules, " ") {
+			t.Errorf("%s: results of rules %q, want %q", test.name, rules, test.rules)
+		}
+
+		invocation := run.Invocations[0]
+		if invocation.ExecutionSuccessful != test.successful {
+			t.Errorf("%s: execution successful = %v, want %v", test.name, invocation.ExecutionSuccessful, test.successful)
+		}
+		if invocation.StartTimeUTC != "2017-06-01T12:00:00Z" || invocation.EndTimeUTC != "2017-06-01T12:00:01Z" {
+			t.Errorf("%s: invocation ran from %s to %s", test.name, invocation.StartTimeUTC, invocation.EndTimeUTC)
+		}
+		if len(invocation.ToolExecutionNotifications) != 1 || invocation.ToolExecutionNotifications[0].Message.Text != "connection reset by peer" {
+			t.Errorf("%s: notifications %+v, want the error of the request that failed", test.name, invocation.ToolExecutionNotifications)
+		}
+	}
+}
+
+func TestSARIFEvidence(t *testing.T) {
+	config, result := testRace()
+	var out bytes.Buffer
+	if err := WriteSARIF(&out, config, result); err != nil {
+		t.Fatal(err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(out.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	exceeded, assertion := log.Runs[0].Results[0], log.Runs[0].Results[1]
+
+	// The request that exceeded its matcher is described, with its credentials redacted
+	req := exceeded.WebRequest
+	if req == nil || req.Method != "POST" || req.Target != "https://shop.test/pay" || req.Body == nil || req.Body.Text != "val=1000" {
+		t.Fatalf("web request %+v, want the payment", req)
+	}
+	for name, want := range map[string]string{"Authorization": "[redacted]", "Cookie": "[redacted]", "Content-Type": "text/plain"} {
+		if req.Headers[name] != want {
+			t.Errorf("request header %s = %q, want %q", name, req.Headers[name], want)
+		}
+	}
+
+	// So is the most successful response
+	resp := exceeded.WebResponse
+	if resp == nil || resp.StatusCode != 200 || resp.Body == nil || resp.Body.Text != "paid" {
+		t.Fatalf("web response %+v, want the successful payment", resp)
+	}
+	if resp.Headers["Set-Cookie"] != "[redacted]" {
+		t.Errorf("response header Set-Cookie = %q, want it redacted", resp.Headers["Set-Cookie"])
+	}
+	if strings.Contains(out.String(), "secret") || strings.Contains(out.String(), "abc") || strings.Contains(out.String(), "receipt=1") {
+		t.Errorf("report holds a credential: %s", out.String())
+	}
+
+	// Results for a single request keep the responses it received, those for assertions keep every response
+	for _, test := range []struct {
+		res       sarifResult
+		responses []int
+		locations int
+	}{
+		{exceeded, []int{1, 2}, 1},
+		{assertion, []int{1, 2, 3}, 2},
+	} {
+		var responses []int
+		for _, info := range test.res.Properties.Responses {
+			responses = append(responses, info.Response)
+		}
+		if fmt.Sprint(responses) != fmt.Sprint(test.responses) || len(test.res.Locations) != test.locations {
+			t.Errorf("result of rule %s has responses %v and %d locations, want %v and %d",
+				test.res.RuleID, responses, len(test.res.Locations), test.responses, test.locations)
+		}
+	}
+	if assertion.WebRequest != nil || assertion.WebResponse != nil {
+		t.Errorf("result of rule %s describes a single request", assertion.RuleID)
+	}
+
+	// Fingerprints only depend on the rule and the requests
+	other := *result
+	other.Responses = other.Responses[:1]
+	out.Reset()
+	if err := WriteSARIF(&out, config, &other); err != nil {
+		t.Fatal(err)
+	}
+	var otherLog sarifLog
+	if err := json.Unmarshal(out.Bytes(), &otherLog); err != nil {
+		t.Fatal(err)
+	}
+	if otherLog.Runs[0].Results[0].PartialFingerprints["raceTheWeb/v1"] != exceeded.PartialFingerprints["raceTheWeb/v1"] {
+		t.Errorf("fingerprint changed with the responses received")
+	}
+	if assertion.PartialFingerprints["raceTheWeb/v1"] == exceeded.PartialFingerprints["raceTheWeb/v1"] {
+		t.Errorf("results of rules %s and %s have the same fingerprint", exceeded.RuleID, assertion.RuleID)
+	}
+}
+
+func TestSampleBody(t *testing.T) {
+	long := strings.Repeat("a", maxSampleBody)
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"short", "paid", "paid"},
+		{"as long as the sample", long, long},
+		{"long", long + "bcd", long + "... (3 more bytes)"},
+		{"character across the cut", long[2:] + "日本", long[2:] + "... (6 more bytes)"},
+		{"character ending at the cut", long[3:] + "日本", long[3:] + "日... (3 more bytes)"},
+		{"character starting at the cut", long + "日", long + "... (3 more bytes)"},
+		{"four byte character across the cut", long[1:] + "😀", long[1:] + "... (4 more bytes)"},
+		{"binary", "\x80" + strings.Repeat("\xbf", maxSampleBody+1), "\x80" + strings.Repeat("\xbf", maxSampleBody-4) + "... (5 more bytes)"},
+	}
+	// Only the ends of the samples are worth showing
+	end := func(s string) string {
+		if len(s) > 30 {
+			return "..." + s[len(s)-30:]
+		}
+		return s
+	}
+	for _, test := range tests {
+		if sample := sampleBody(test.body); sample != test.want {
+			t.Errorf("%s: sampleBody cut %d bytes to %q, want %q", test.name, len(test.body), end(sample), end(test.want))
+		}
+		if utf8.ValidString(test.body) && !utf8.ValidString(sampleBody(test.body)) {
+			t.Errorf("%s: sampleBody split a character", test.name)
+		}
+	}
+}